@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/indeedhat/term-gpt/internal/config"
+	"github.com/indeedhat/term-gpt/internal/credentials"
+	"golang.org/x/term"
+)
+
+const authUsage = `usage: term-gpt auth [--config path] [--profile name] <command>
+
+commands:
+  set         store the api key for the profile
+  rotate      replace the existing api key for the profile
+  remove      delete the api key for the profile
+  status      show which backend is in use and if a key is stored
+  passphrase  change the passphrase of the encrypted credentials file
+`
+
+// runAuth handles the `term-gpt auth` sub command for managing stored api keys
+func runAuth(args []string) {
+	fs := flag.NewFlagSet("auth", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, authUsage)
+	}
+
+	opts := configFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	conf, err := config.Load(*opts)
+	if err != nil {
+		log.Fatalf("config error: %s", err)
+	}
+
+	provider, err := credentialProvider(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	name := credentials.KeyName(conf.Profile)
+
+	switch fs.Arg(0) {
+	case "set":
+		err = authSet(provider, name)
+	case "rotate":
+		err = authRotate(provider, name)
+	case "remove":
+		err = provider.Delete(name)
+		if err == nil {
+			fmt.Printf("removed %s\n", name)
+		}
+	case "status":
+		err = authStatus(provider, conf, name)
+	case "passphrase":
+		file, ok := provider.(*credentials.FileProvider)
+		if !ok {
+			log.Fatalf("the %s backend does not use a passphrase", conf.CredentialBackend)
+		}
+
+		err = file.ChangePassphrase()
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// authSet prompts for a new api key and stores it
+func authSet(provider credentials.Provider, name string) error {
+	key, err := promptSecret(fmt.Sprintf("API key for %s: ", name))
+	if err != nil {
+		return err
+	}
+
+	if err := provider.Set(name, key); err != nil {
+		return err
+	}
+
+	fmt.Printf("stored %s (%s)\n", name, credentials.Mask(key))
+
+	return nil
+}
+
+// authRotate replaces an existing api key with a new one
+func authRotate(provider credentials.Provider, name string) error {
+	old, err := provider.Get(name)
+	if errors.Is(err, credentials.ErrNotFound) {
+		return fmt.Errorf("no key is stored for %s, use `term-gpt auth set` instead", name)
+	} else if err != nil {
+		return err
+	}
+
+	key, err := promptSecret(fmt.Sprintf("New API key for %s: ", name))
+	if err != nil {
+		return err
+	}
+
+	if key == old {
+		return errors.New("the new key is the same as the existing key")
+	}
+
+	if err := provider.Set(name, key); err != nil {
+		return err
+	}
+
+	fmt.Printf("rotated %s (%s -> %s)\n", name, credentials.Mask(old), credentials.Mask(key))
+
+	return nil
+}
+
+// authStatus prints the backend in use and whether a key is stored for the profile
+func authStatus(provider credentials.Provider, conf *config.Config, name string) error {
+	fmt.Printf("backend: %s\n", conf.CredentialBackend)
+	if conf.CredentialBackend == credentials.BackendFile {
+		fmt.Printf("file:    %s\n", conf.CredentialsFile)
+	}
+
+	key, err := provider.Get(name)
+	switch {
+	case errors.Is(err, credentials.ErrNotFound):
+		fmt.Printf("%s: not set\n", name)
+	case err != nil:
+		return err
+	default:
+		fmt.Printf("%s: %s\n", name, credentials.Mask(key))
+	}
+
+	if conf.EnvFallback {
+		fmt.Println("env fallback: enabled")
+	}
+
+	return nil
+}
+
+// promptPassphrase asks the user for the passphrase of the encrypted credentials file
+// when creating a new file the passphrase has to be entered twice
+func promptPassphrase(confirm bool) (string, error) {
+	pass, err := promptSecret("Credentials passphrase: ")
+	if err != nil || !confirm {
+		return pass, err
+	}
+
+	again, err := promptSecret("Confirm passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	if pass != again {
+		return "", errors.New("passphrases do not match")
+	}
+
+	return pass, nil
+}
+
+// promptSecret reads a line from the terminal without echoing it
+func promptSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("cannot prompt for secrets without a terminal")
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", errors.New("value must not be empty")
+	}
+
+	return secret, nil
+}
@@ -1,35 +1,109 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/indeedhat/term-gpt/internal/config"
+	"github.com/indeedhat/term-gpt/internal/credentials"
 	"github.com/indeedhat/term-gpt/internal/gpt"
+	"github.com/indeedhat/term-gpt/internal/mcp"
+	"github.com/indeedhat/term-gpt/internal/retry"
 	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/indeedhat/term-gpt/internal/tools"
 
-	"github.com/indeedhat/term-gpt/internal/env"
 	"github.com/sashabaranov/go-openai"
 )
 
 func main() {
-	conf := openai.DefaultConfig(env.Get(env.OpenAiToken))
-	if org := env.Get(env.OpenAiOrg); org != "" {
-		conf.OrgID = env.Get(env.OpenAiOrg)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "auth":
+			runAuth(os.Args[2:])
+			return
+		case "usage":
+			runUsage(os.Args[2:])
+			return
+		}
 	}
+
+	opts := configFlags(flag.CommandLine)
+	flag.StringVar(&opts.Model, "model", "", "model to use for requests")
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run starts the chat ui, it returns once the ui is closed
+// the servers started along the way are shut down before any error is returned
+func run(opts *config.Options) error {
+	appConf, err := config.Load(*opts)
+	if err != nil {
+		return fmt.Errorf("config error: %w", err)
+	}
+
+	provider, err := credentialProvider(appConf)
+	if err != nil {
+		return err
+	}
+
+	var fallback string
+	if appConf.EnvFallback {
+		fallback = appConf.OpenAiToken
+	}
+
+	token, err := credentials.Resolve(provider, appConf.Profile, fallback)
+	if err != nil && appConf.BaseURL == "" {
+		return err
+	}
+
+	conf := openai.DefaultConfig(token)
+	if appConf.OpenAiOrg != "" {
+		conf.OrgID = appConf.OpenAiOrg
+	}
+	if appConf.BaseURL != "" {
+		conf.BaseURL = appConf.BaseURL
+	}
+	conf.HTTPClient = retry.NewHTTPClient()
 	client := openai.NewClientWithConfig(conf)
 
-	db, err := store.Connect()
+	db, err := store.Connect(appConf.Database)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	if err := store.AutoMigrate(db); err != nil {
+		return err
 	}
 
 	repo := store.NewChatHistorySqliteRepo(db)
-	if err := repo.MigrateSchema(); err != nil {
-		log.Fatal(err)
+	usageRepo := store.NewUsageSqliteRepo(db)
+
+	mcpConf, err := mcp.LoadConfig(appConf.McpConfig)
+	if err != nil {
+		return err
+	}
+
+	registry := tools.Builtin()
+	servers := mcp.ConnectAll(context.Background(), mcpConf)
+	for _, server := range servers {
+		defer server.Close()
+		server.Register(registry)
+	}
+
+	progOpts := []tea.ProgramOption{tea.WithAltScreen()}
+	if appConf.Mouse {
+		progOpts = append(progOpts, tea.WithMouseCellMotion())
 	}
 
-	prog := tea.NewProgram(gpt.New(repo, client), tea.WithAltScreen())
+	prog := tea.NewProgram(gpt.New(appConf, repo, usageRepo, client, registry, servers), progOpts...)
 
 	// horrible hack
 	go func() {
@@ -37,7 +111,27 @@ func main() {
 		prog.Send(prog)
 	}()
 
-	if _, err := prog.Run(); err != nil {
-		log.Fatal(err)
-	}
+	_, err = prog.Run()
+
+	return err
+}
+
+// configFlags registers the flags used to load the config on the given flag set
+func configFlags(fs *flag.FlagSet) *config.Options {
+	var opts config.Options
+
+	fs.StringVar(&opts.Path, "config", "", "path to the config file (default $XDG_CONFIG_HOME/term-gpt/config.toml)")
+	fs.StringVar(&opts.Profile, "profile", "", "name of the config profile to use")
+
+	return &opts
+}
+
+// credentialProvider sets up the credential provider for the configured backend
+func credentialProvider(conf *config.Config) (credentials.Provider, error) {
+	return credentials.New(credentials.Options{
+		Backend:    conf.CredentialBackend,
+		File:       conf.CredentialsFile,
+		EnvKey:     conf.OpenAiToken,
+		Passphrase: promptPassphrase,
+	})
 }
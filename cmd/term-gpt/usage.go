@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/indeedhat/term-gpt/internal/config"
+	"github.com/indeedhat/term-gpt/internal/store"
+)
+
+// maxReportChats limits the number of chats shown in the per chat usage report
+const maxReportChats = 20
+
+// runUsage handles the `term-gpt usage` sub command for reporting on token spend
+func runUsage(args []string) {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	opts := configFlags(fs)
+	days := fs.Int("days", 30, "number of days to include in the report")
+	fs.Parse(args)
+
+	conf, err := config.Load(*opts)
+	if err != nil {
+		log.Fatalf("config error: %s", err)
+	}
+
+	db, err := store.Connect(conf.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := store.AutoMigrate(db); err != nil {
+		log.Fatal(err)
+	}
+
+	repo := store.NewUsageSqliteRepo(db)
+	since := time.Now().AddDate(0, 0, -*days)
+
+	fmt.Printf("Usage for the last %d days: $%.4f\n", *days, repo.SpendSince(since))
+
+	printSummaries("Day", repo.ByDay(since), 0)
+	printSummaries("Model", repo.ByModel(since), 0)
+	printSummaries("Chat", repo.ByChat(since), maxReportChats)
+}
+
+// printSummaries prints a usage report table, limit 0 == no limit
+func printSummaries(heading string, summaries []store.UsageSummary, limit int) {
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tRequests\tPrompt\tCompletion\tCost\n", heading)
+
+	for i, summary := range summaries {
+		if limit > 0 && i >= limit {
+			fmt.Fprintf(w, "... %d more\t\t\t\t\n", len(summaries)-limit)
+			break
+		}
+
+		fmt.Fprintf(
+			w,
+			"%s\t%d\t%d\t%d\t$%.4f\n",
+			truncateTitle(summary.Key),
+			summary.Requests,
+			summary.PromptTokens,
+			summary.CompletionTokens,
+			summary.Cost,
+		)
+	}
+
+	w.Flush()
+}
+
+// truncateTitle keeps chat titles short enough to fit in the report table
+func truncateTitle(title string) string {
+	runes := []rune(title)
+	if len(runes) <= 40 {
+		return title
+	}
+
+	return string(runes[:39]) + "…"
+}
@@ -0,0 +1,33 @@
+package clipboard
+
+import (
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+)
+
+// Copy sets the system clipboard to text using the OSC 52 escape sequence
+//
+// OSC 52 is handled by the terminal emulator itself so it works over SSH. When running inside tmux
+// the sequence is sent both as is (picked up when tmux has set-clipboard on) and wrapped in a DCS
+// passthrough (forwarded to the outer terminal when tmux has allow-passthrough on)
+func Copy(w io.Writer, text string) error {
+	seq := sequence(text)
+	if os.Getenv("TMUX") != "" {
+		seq += tmuxPassthrough(seq)
+	}
+
+	_, err := io.WriteString(w, seq)
+	return err
+}
+
+// sequence builds the OSC 52 sequence that sets the clipboard selection to text
+func sequence(text string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\x07"
+}
+
+// tmuxPassthrough wraps a sequence so tmux passes it through to the outer terminal unchanged
+func tmuxPassthrough(seq string) string {
+	return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+}
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/indeedhat/term-gpt/internal/credentials"
+	"github.com/indeedhat/term-gpt/internal/env"
+	"github.com/indeedhat/term-gpt/internal/keymap"
+	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/indeedhat/term-gpt/internal/theme"
+	"github.com/indeedhat/term-gpt/internal/usage"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	appName             = "term-gpt"
+	configFileName      = "config.toml"
+	credentialsFileName = "credentials.enc"
+	themeDirName        = "themes"
+
+	// ProfileEnv selects the profile to use when one is not given on the command line
+	ProfileEnv = "TERM_GPT_PROFILE"
+)
+
+// Config holds the fully resolved settings for the app
+type Config struct {
+	// Profile is the name of the active profile, it is empty if no profile is in use
+	Profile string `toml:"profile"`
+
+	// OpenAiToken is only used when CredentialBackend is env or EnvFallback is enabled
+	OpenAiToken string `toml:"openai_token"`
+	OpenAiOrg   string `toml:"openai_org"`
+	// BaseURL overrides the openai API url, this allows for using openai compatible servers
+	BaseURL string `toml:"base_url"`
+
+	// CredentialBackend is the name of the credentials.Provider backend used to store the api key
+	CredentialBackend string `toml:"credential_backend"`
+	// CredentialsFile is the path of the encrypted credentials file used by the file backend
+	CredentialsFile string `toml:"credentials_file"`
+	// EnvFallback allows OpenAiToken to be used when no key is stored in the credential backend
+	EnvFallback bool `toml:"env_fallback"`
+
+	// Model is the model used for requests
+	Model string `toml:"model"`
+	// VisionModel is used in place of Model when the chat contains images
+	VisionModel string `toml:"vision_model"`
+	// MaxRequestTokens is the max number of tokens the model can generate per request, 0 == no limit
+	MaxRequestTokens int `toml:"max_request_tokens"`
+	// MaxPrevMessages is the max number of previous messages sent with each request, 0 == full chat
+	MaxPrevMessages int `toml:"max_prev_messages"`
+	// MaxRetries is the number of times a request is retried after a rate limit or transient error
+	MaxRetries int `toml:"max_retries"`
+
+	// Pricing overrides/extends the built in pricing table used to calculate the cost of requests
+	Pricing usage.Pricing `toml:"pricing"`
+	// ContextWindows overrides/extends the built in table of model context sizes shown in the status bar
+	ContextWindows usage.ContextWindows `toml:"context_windows"`
+	// Budget sets the daily/monthly spending limits
+	Budget usage.Budget `toml:"budget"`
+
+	// Sampling holds the default sampling parameters, these can be overridden per chat
+	Sampling store.Sampling `toml:"sampling"`
+
+	// SendKey is the key used to send the prompt
+	SendKey string `toml:"send_key"`
+	// Keys configures the key bindings
+	Keys Keys `toml:"keys"`
+	// Mouse enables scrolling the chat with the mouse wheel, while it is on most terminals need
+	// shift held to select text
+	Mouse bool `toml:"mouse"`
+	// Layout configures the size and visibility of the panes
+	Layout Layout `toml:"layout"`
+	// Theme is the name of the colour theme, either a built in theme or one from ThemeDir
+	Theme string `toml:"theme"`
+	// ThemeDir is the directory user themes are loaded from, each theme is a toml file
+	ThemeDir string `toml:"theme_dir"`
+
+	// Database is the path to the sqlite database used to store chat history
+	Database string `toml:"database"`
+	// McpConfig is the path to the json file defining the MCP servers to connect to
+	McpConfig string `toml:"mcp_config"`
+}
+
+// Keys selects the key binding preset and any bindings that override it
+type Keys struct {
+	// Preset is the name of the base key map (default, vim or emacs)
+	Preset string `toml:"preset"`
+	// Bindings maps action names to the keys that trigger them
+	Bindings map[string][]string `toml:"bindings"`
+}
+
+// Layout configures the chat history sidebar
+type Layout struct {
+	// Sidebar shows the chat history sidebar on start up
+	Sidebar bool `toml:"sidebar"`
+	// SidebarWidth is the fraction of the window width given to the sidebar
+	SidebarWidth float64 `toml:"sidebar_width"`
+	// CompactWidth is the window width below which only one pane is shown at a time
+	CompactWidth int `toml:"compact_width"`
+}
+
+// Options are the command line overrides used when loading the config
+type Options struct {
+	// Path to the config file, if empty the default location in the XDG config dir is used
+	Path string
+	// Profile is the name of the profile to use
+	Profile string
+	// Model overrides the model set in the config file/env
+	Model string
+}
+
+// Default returns the config used for any values not set in the config file, env or flags
+func Default() Config {
+	return Config{
+		CredentialBackend: credentials.BackendFile,
+		CredentialsFile:   defaultCredentialsPath(),
+		Model:             openai.GPT3Dot5Turbo,
+		VisionModel:       openai.GPT4VisionPreview,
+		MaxRequestTokens:  2000,
+		MaxRetries:        3,
+		Budget:            usage.Budget{Action: usage.BudgetBlock},
+		SendKey:           "enter",
+		Keys:              Keys{Preset: keymap.PresetDefault},
+		Mouse:             true,
+		Layout:            Layout{Sidebar: true, SidebarWidth: 0.25, CompactWidth: 60},
+		Theme:             theme.NameAuto,
+		ThemeDir:          defaultThemeDir(),
+		Database:          "chatLog.db",
+		McpConfig:         "mcp.json",
+	}
+}
+
+// DefaultPath returns the location of the config file within the XDG config dir
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, appName, configFileName), nil
+}
+
+// defaultCredentialsPath returns the location of the encrypted credentials file within the XDG
+// config dir, if the config dir cannot be found the file is kept in the working directory
+func defaultCredentialsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return credentialsFileName
+	}
+
+	return filepath.Join(dir, appName, credentialsFileName)
+}
+
+// defaultThemeDir is the directory user themes are loaded from when theme_dir is not set
+func defaultThemeDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return themeDirName
+	}
+
+	return filepath.Join(dir, appName, themeDirName)
+}
+
+// Load builds the config by merging the defaults, config file, active profile, environment and
+// command line options (in that order), the resulting config is validated before it is returned
+func Load(opts Options) (*Config, error) {
+	conf := Default()
+
+	path := opts.Path
+	if path == "" {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	profiles, err := loadFile(path, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := conf.Profile
+	if name := env.Get(ProfileEnv); name != "" {
+		profile = name
+	}
+	if opts.Profile != "" {
+		profile = opts.Profile
+	}
+
+	if profile != "" {
+		overlay, ok := profiles[profile]
+		if !ok {
+			return nil, unknownProfileError(profile, profiles)
+		}
+
+		conf = overlay
+		conf.Profile = profile
+	}
+
+	conf.Pricing = usage.DefaultPricing.Merge(conf.Pricing)
+	conf.ContextWindows = usage.DefaultContextWindows.Merge(conf.ContextWindows)
+
+	if err := applyEnv(&conf); err != nil {
+		return nil, err
+	}
+
+	if opts.Model != "" {
+		conf.Model = opts.Model
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &conf, nil
+}
+
+// Validate checks that the config is usable
+func (c Config) Validate() error {
+	var errs []error
+
+	if !slices.Contains(credentials.Backends, c.CredentialBackend) {
+		errs = append(errs, fmt.Errorf(
+			"credential_backend must be one of %s, got %q",
+			strings.Join(credentials.Backends, ", "),
+			c.CredentialBackend,
+		))
+	}
+	if c.CredentialBackend == credentials.BackendEnv && c.OpenAiToken == "" && c.BaseURL == "" {
+		errs = append(errs, fmt.Errorf("openai_token is required (set it in the config file or %s)", env.OpenAiToken))
+	}
+	if c.CredentialBackend == credentials.BackendFile && c.CredentialsFile == "" {
+		errs = append(errs, errors.New("credentials_file must not be empty"))
+	}
+	if c.Model == "" {
+		errs = append(errs, errors.New("model must not be empty"))
+	}
+	if c.VisionModel == "" {
+		errs = append(errs, errors.New("vision_model must not be empty"))
+	}
+	if c.MaxRequestTokens < 0 {
+		errs = append(errs, errors.New("max_request_tokens must not be negative"))
+	}
+	if c.MaxPrevMessages < 0 {
+		errs = append(errs, errors.New("max_prev_messages must not be negative"))
+	}
+	if c.MaxRetries < 0 {
+		errs = append(errs, errors.New("max_retries must not be negative"))
+	}
+	if err := c.Budget.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid budget config: %w", err))
+	}
+	if err := c.Sampling.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("invalid sampling config: %w", err))
+	}
+	if c.SendKey == "" {
+		errs = append(errs, errors.New("send_key must not be empty"))
+	}
+	if _, err := keymap.New(c.Keys.Preset, c.SendKey, c.Keys.Bindings); err != nil {
+		errs = append(errs, fmt.Errorf("invalid keys config: %w", err))
+	}
+	if c.Layout.SidebarWidth < 0.1 || c.Layout.SidebarWidth > 0.6 {
+		errs = append(errs, errors.New("layout.sidebar_width must be between 0.1 and 0.6"))
+	}
+	if c.Layout.CompactWidth < 0 {
+		errs = append(errs, errors.New("layout.compact_width must not be negative"))
+	}
+	if themes, err := theme.All(c.ThemeDir); err != nil {
+		errs = append(errs, fmt.Errorf("invalid themes: %w", err))
+	} else if _, ok := theme.Find(themes, c.Theme); !ok {
+		errs = append(errs, fmt.Errorf("unknown theme %q", c.Theme))
+	}
+	if c.Database == "" {
+		errs = append(errs, errors.New("database must not be empty"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// loadFile decodes the config file at path over the top of conf
+//
+// Each of the profiles defined in the file is returned as a copy of conf with the profile values
+// applied over the top of it
+// A missing config file is not an error, the app can be configured entirely through the env
+func loadFile(path string, conf *Config) (map[string]Config, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	var file struct {
+		Config
+		Profiles map[string]toml.Primitive `toml:"profiles"`
+	}
+	file.Config = *conf
+
+	meta, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	*conf = file.Config
+
+	profiles := make(map[string]Config, len(file.Profiles))
+	for name, prim := range file.Profiles {
+		profile := conf.clone()
+		if err := meta.PrimitiveDecode(prim, &profile); err != nil {
+			return nil, fmt.Errorf("invalid profile %s in %s: %w", name, path, err)
+		}
+
+		profiles[name] = profile
+	}
+
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, 0, len(undecoded))
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+
+		return nil, fmt.Errorf("unknown keys in config file %s: %s", path, strings.Join(keys, ", "))
+	}
+
+	return profiles, nil
+}
+
+// clone returns a copy of the config that does not share any maps with the original so that
+// decoding a profile over the top of it cannot leak into the other profiles
+func (c Config) clone() Config {
+	c.Pricing = maps.Clone(c.Pricing)
+	c.ContextWindows = maps.Clone(c.ContextWindows)
+	c.Keys.Bindings = maps.Clone(c.Keys.Bindings)
+
+	return c
+}
+
+// applyEnv overrides the config with any values set in the environment
+// unlike env.GetInt invalid numbers are reported rather than silently ignored
+func applyEnv(conf *Config) error {
+	strs := map[string]*string{
+		env.OpenAiToken: &conf.OpenAiToken,
+		env.OpenAiOrg:   &conf.OpenAiOrg,
+		env.BaseURL:     &conf.BaseURL,
+		env.Model:       &conf.Model,
+		env.VisionModel: &conf.VisionModel,
+		env.SendKey:     &conf.SendKey,
+		env.Database:    &conf.Database,
+		env.McpConfig:   &conf.McpConfig,
+
+		env.CredentialBackend: &conf.CredentialBackend,
+	}
+	for key, field := range strs {
+		if val := env.Get(key); val != "" {
+			*field = val
+		}
+	}
+
+	ints := map[string]*int{
+		env.MaxRequestTokens: &conf.MaxRequestTokens,
+		env.MaxPrevMesgs:     &conf.MaxPrevMessages,
+	}
+	for key, field := range ints {
+		val := env.Get(key)
+		if val == "" {
+			continue
+		}
+
+		i, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number, got %q", key, val)
+		}
+
+		*field = i
+	}
+
+	return nil
+}
+
+// unknownProfileError builds a helpful error listing the available profiles
+func unknownProfileError(name string, profiles map[string]Config) error {
+	if len(profiles) == 0 {
+		return fmt.Errorf("unknown profile %q, no profiles are defined in the config file", name)
+	}
+
+	names := make([]string, 0, len(profiles))
+	for profile := range profiles {
+		names = append(names, profile)
+	}
+	sort.Strings(names)
+
+	return fmt.Errorf("unknown profile %q, available profiles: %s", name, strings.Join(names, ", "))
+}
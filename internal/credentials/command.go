@@ -0,0 +1,116 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// SecretServiceProvider stores keys in the desktop keyring (gnome-keyring, kwallet, keepassxc etc.)
+// through the freedesktop Secret Service API using the secret-tool cli from libsecret
+type SecretServiceProvider struct{}
+
+// Get implements Provider.
+func (SecretServiceProvider) Get(name string) (string, error) {
+	out, err := run("secret-tool", nil, "lookup", "service", service, "account", name)
+	if err != nil {
+		// secret-tool exits with 1 and no output when the secret does not exist
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", ErrNotFound
+		}
+
+		return "", err
+	}
+
+	if out == "" {
+		return "", ErrNotFound
+	}
+
+	return out, nil
+}
+
+// Set implements Provider.
+func (SecretServiceProvider) Set(name, secret string) error {
+	_, err := run(
+		"secret-tool",
+		strings.NewReader(secret),
+		"store", "--label", fmt.Sprintf("%s (%s)", service, name),
+		"service", service,
+		"account", name,
+	)
+
+	return err
+}
+
+// Delete implements Provider.
+func (SecretServiceProvider) Delete(name string) error {
+	_, err := run("secret-tool", nil, "clear", "service", service, "account", name)
+	return err
+}
+
+var _ Provider = (*SecretServiceProvider)(nil)
+
+// PassProvider stores keys in the standard unix password manager (pass)
+type PassProvider struct{}
+
+// Get implements Provider.
+func (PassProvider) Get(name string) (string, error) {
+	out, err := run("pass", nil, "show", passPath(name))
+	if err != nil {
+		if strings.Contains(err.Error(), "is not in the password store") {
+			return "", ErrNotFound
+		}
+
+		return "", err
+	}
+
+	// by convention the first line of a pass entry is the secret
+	key, _, _ := strings.Cut(out, "\n")
+
+	return key, nil
+}
+
+// Set implements Provider.
+func (PassProvider) Set(name, secret string) error {
+	_, err := run("pass", strings.NewReader(secret+"\n"), "insert", "--multiline", "--force", passPath(name))
+	return err
+}
+
+// Delete implements Provider.
+func (PassProvider) Delete(name string) error {
+	_, err := run("pass", nil, "rm", "--force", passPath(name))
+	return err
+}
+
+var _ Provider = (*PassProvider)(nil)
+
+// passPath builds the path of the entry within the password store
+func passPath(name string) string {
+	return service + "/" + name
+}
+
+// run executes a backend cli and returns its trimmed stdout
+// stderr is included in the error if the command fails
+func run(name string, stdin *strings.Reader, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
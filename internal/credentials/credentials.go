@@ -0,0 +1,126 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	BackendFile          = "file"
+	BackendSecretService = "secret-service"
+	BackendPass          = "pass"
+	// BackendEnv reads the key straight from the config file/env vars and cannot store keys
+	BackendEnv = "env"
+
+	// service is used to namespace the keys stored in shared backends
+	service = "term-gpt"
+)
+
+var (
+	ErrNotFound = errors.New("credential not found")
+	ErrReadOnly = errors.New("credential backend is read only")
+)
+
+// Backends lists all the supported credential backends
+var Backends = []string{BackendFile, BackendSecretService, BackendPass, BackendEnv}
+
+// Provider stores and retrieves API keys
+type Provider interface {
+	// Get retrieves the key stored under name
+	// ErrNotFound is returned if there is no key stored under that name
+	Get(name string) (string, error)
+	// Set stores the key under name replacing any existing key
+	Set(name, secret string) error
+	// Delete removes the key stored under name
+	Delete(name string) error
+}
+
+// PassphraseFunc is called when the encrypted file backend needs the passphrase from the user
+type PassphraseFunc func(confirm bool) (string, error)
+
+// Options configures the credential provider
+type Options struct {
+	// Backend is the name of the backend to use
+	Backend string
+	// File is the path of the encrypted credentials file
+	File string
+	// EnvKey is the key returned by the env backend
+	EnvKey string
+	// Passphrase is used to get the passphrase for the encrypted file backend
+	Passphrase PassphraseFunc
+}
+
+// New creates the credential provider for the configured backend
+func New(opts Options) (Provider, error) {
+	switch opts.Backend {
+	case BackendFile:
+		return NewFileProvider(opts.File, opts.Passphrase), nil
+	case BackendSecretService:
+		return SecretServiceProvider{}, nil
+	case BackendPass:
+		return PassProvider{}, nil
+	case BackendEnv:
+		return EnvProvider{Key: opts.EnvKey}, nil
+	default:
+		return nil, fmt.Errorf("unknown credential backend %q", opts.Backend)
+	}
+}
+
+// KeyName returns the name the openai key for the given profile is stored under
+func KeyName(profile string) string {
+	if profile == "" {
+		return "openai"
+	}
+
+	return "openai-" + profile
+}
+
+// Resolve looks up the openai key for the given profile
+//
+// If the key cannot be found and fallback is not empty then fallback will be used in its place,
+// this allows keys set through env vars to be used but only when explicitly enabled
+func Resolve(provider Provider, profile, fallback string) (string, error) {
+	key, err := provider.Get(KeyName(profile))
+	if errors.Is(err, ErrNotFound) && fallback != "" {
+		return fallback, nil
+	} else if errors.Is(err, ErrNotFound) {
+		return "", fmt.Errorf("no api key has been set for %s, run `term-gpt auth set` to add one", KeyName(profile))
+	}
+
+	return key, err
+}
+
+// Mask hides all but the last 4 chars of a key so it can be safely displayed
+func Mask(key string) string {
+	if len(key) <= 8 {
+		return "********"
+	}
+
+	return "********" + key[len(key)-4:]
+}
+
+// EnvProvider serves the key loaded from the config file/env vars
+type EnvProvider struct {
+	Key string
+}
+
+// Get implements Provider.
+func (p EnvProvider) Get(string) (string, error) {
+	if p.Key == "" {
+		return "", ErrNotFound
+	}
+
+	return p.Key, nil
+}
+
+// Set implements Provider.
+func (EnvProvider) Set(string, string) error {
+	return ErrReadOnly
+}
+
+// Delete implements Provider.
+func (EnvProvider) Delete(string) error {
+	return ErrReadOnly
+}
+
+var _ Provider = (*EnvProvider)(nil)
@@ -0,0 +1,252 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt parameters as recommended for interactive logins
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	keyLength    = 32
+	saltLength   = 16
+	fileVersion  = 1
+	filePerm     = 0o600
+	fileDirPerm  = 0o700
+	fileTempName = ".credentials-*"
+)
+
+var ErrBadPassphrase = errors.New("incorrect passphrase or corrupt credentials file")
+
+// FileProvider stores keys in a local file encrypted with AES-GCM using a key derived from the
+// users passphrase with scrypt
+type FileProvider struct {
+	path       string
+	passphrase PassphraseFunc
+
+	// key and salt are cached after the first successful unlock so the user is only asked once
+	key  []byte
+	salt []byte
+}
+
+// encryptedFile is the on disk format of the credentials file
+type encryptedFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// NewFileProvider sets up the encrypted file provider for the file at path
+func NewFileProvider(path string, passphrase PassphraseFunc) *FileProvider {
+	return &FileProvider{path: path, passphrase: passphrase}
+}
+
+// Get implements Provider.
+func (p *FileProvider) Get(name string) (string, error) {
+	keys, err := p.load()
+	if err != nil {
+		return "", err
+	}
+
+	key, ok := keys[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return key, nil
+}
+
+// Set implements Provider.
+func (p *FileProvider) Set(name, secret string) error {
+	keys, err := p.load()
+	if err != nil {
+		return err
+	}
+
+	keys[name] = secret
+
+	return p.save(keys)
+}
+
+// Delete implements Provider.
+func (p *FileProvider) Delete(name string) error {
+	keys, err := p.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := keys[name]; !ok {
+		return ErrNotFound
+	}
+
+	delete(keys, name)
+
+	return p.save(keys)
+}
+
+// ChangePassphrase re encrypts the credentials file with a new passphrase
+func (p *FileProvider) ChangePassphrase() error {
+	keys, err := p.load()
+	if err != nil {
+		return err
+	}
+
+	p.key = nil
+	p.salt = nil
+
+	return p.save(keys)
+}
+
+// load decrypts the credentials file
+// if the file does not exist an empty set of keys is returned
+func (p *FileProvider) load() (map[string]string, error) {
+	data, err := os.ReadFile(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var file encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, ErrBadPassphrase
+	}
+
+	if p.key == nil {
+		passphrase, err := p.passphrase(false)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.key, err = deriveKey(passphrase, file.Salt); err != nil {
+			return nil, err
+		}
+		p.salt = file.Salt
+	}
+
+	gcm, err := newGCM(p.key)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		p.key = nil
+		return nil, ErrBadPassphrase
+	}
+
+	keys := map[string]string{}
+	if err := json.Unmarshal(plain, &keys); err != nil {
+		return nil, ErrBadPassphrase
+	}
+
+	return keys, nil
+}
+
+// save encrypts the keys and atomically replaces the credentials file
+func (p *FileProvider) save(keys map[string]string) error {
+	if p.key == nil {
+		passphrase, err := p.passphrase(true)
+		if err != nil {
+			return err
+		}
+
+		p.salt = make([]byte, saltLength)
+		if _, err := rand.Read(p.salt); err != nil {
+			return err
+		}
+
+		if p.key, err = deriveKey(passphrase, p.salt); err != nil {
+			return err
+		}
+	}
+
+	plain, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(p.key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(encryptedFile{
+		Version: fileVersion,
+		Salt:    p.salt,
+		Nonce:   nonce,
+		Data:    gcm.Seal(nil, nonce, plain, nil),
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(p.path, data)
+}
+
+// deriveKey derives the encryption key from the users passphrase
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase must not be empty")
+	}
+
+	return scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keyLength)
+}
+
+// newGCM sets up the AES-GCM cipher for the given key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// writeFileAtomic writes data to a temp file before moving it into place so a failed write
+// cannot leave a half written credentials file behind
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, fileDirPerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, fileTempName)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(filePerm); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+var _ Provider = (*FileProvider)(nil)
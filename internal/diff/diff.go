@@ -0,0 +1,219 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxCells limits the size of the lcs table, bigger inputs fall back to replacing the changed
+// lines wholesale rather than using a huge amount of memory
+const maxCells = 4_000_000
+
+// Op is the kind of change made to a line
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Edit is a single line of a diff
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// Hunk is a group of edits along with the lines of context around them
+type Hunk struct {
+	// OldStart and NewStart are the 1 based line numbers the hunk starts on in each file
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Edits    []Edit
+}
+
+// Header formats the @@ line for the hunk
+func (h Hunk) Header() string {
+	oldStart, newStart := h.OldStart, h.NewStart
+	// an empty range refers to the line before the hunk
+	if h.OldLines == 0 {
+		oldStart--
+	}
+	if h.NewLines == 0 {
+		newStart--
+	}
+
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldStart, h.OldLines, newStart, h.NewLines)
+}
+
+// SplitLines splits text into lines, a trailing newline does not start an extra empty line
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Lines computes the edits that turn a into b using the longest common subsequence of lines
+func Lines(a, b []string) []Edit {
+	var prefix, suffix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{Equal, line})
+	}
+
+	edits = append(edits, lcs(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Equal, line})
+	}
+
+	return edits
+}
+
+// lcs diffs the changed middle section of the inputs
+func lcs(a, b []string) []Edit {
+	var edits []Edit
+
+	if len(a)*len(b) > maxCells {
+		for _, line := range a {
+			edits = append(edits, Edit{Delete, line})
+		}
+		for _, line := range b {
+			edits = append(edits, Edit{Insert, line})
+		}
+
+		return edits
+	}
+
+	// table[i][j] is the length of the lcs of a[i:] and b[j:]
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, Edit{Equal, a[i]})
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			edits = append(edits, Edit{Delete, a[i]})
+			i++
+		default:
+			edits = append(edits, Edit{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		edits = append(edits, Edit{Delete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, Edit{Insert, b[j]})
+	}
+
+	return edits
+}
+
+// Hunks groups the changes into hunks with up to context lines of unchanged text around them
+// changes separated by less than twice the context are joined into a single hunk
+func Hunks(edits []Edit, context int) []Hunk {
+	var (
+		hunks   []Hunk
+		oldPos  = make([]int, len(edits))
+		newPos  = make([]int, len(edits))
+		changes []int
+	)
+
+	oldLine, newLine := 1, 1
+	for i, e := range edits {
+		oldPos[i], newPos[i] = oldLine, newLine
+
+		if e.Op != Insert {
+			oldLine++
+		}
+		if e.Op != Delete {
+			newLine++
+		}
+		if e.Op != Equal {
+			changes = append(changes, i)
+		}
+	}
+
+	for start := 0; start < len(changes); {
+		end := start
+		for end+1 < len(changes) && changes[end+1]-changes[end]-1 <= 2*context {
+			end++
+		}
+
+		lo := max(0, changes[start]-context)
+		hi := min(len(edits), changes[end]+context+1)
+
+		hunk := Hunk{OldStart: oldPos[lo], NewStart: newPos[lo], Edits: edits[lo:hi]}
+		for _, e := range hunk.Edits {
+			if e.Op != Insert {
+				hunk.OldLines++
+			}
+			if e.Op != Delete {
+				hunk.NewLines++
+			}
+		}
+
+		hunks = append(hunks, hunk)
+		start = end + 1
+	}
+
+	return hunks
+}
+
+// Unified formats the difference between two texts as a unified diff, an empty string is returned
+// if the texts are the same
+func Unified(oldName, newName, a, b string, context int) string {
+	hunks := Hunks(Lines(SplitLines(a), SplitLines(b)), context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+
+	for _, hunk := range hunks {
+		buf.WriteString(hunk.Header() + "\n")
+
+		for _, e := range hunk.Edits {
+			switch e.Op {
+			case Equal:
+				buf.WriteString(" ")
+			case Insert:
+				buf.WriteString("+")
+			case Delete:
+				buf.WriteString("-")
+			}
+
+			buf.WriteString(e.Text + "\n")
+		}
+	}
+
+	return buf.String()
+}
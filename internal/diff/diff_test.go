@@ -0,0 +1,119 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lines splits a compact description of a file into its lines, each character is a line
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "")
+}
+
+// edits builds the edits of a diff from lines written as they appear in a diff
+func edits(lines ...string) []Edit {
+	ops := map[byte]Op{' ': Equal, '+': Insert, '-': Delete}
+
+	out := make([]Edit, 0, len(lines))
+	for _, line := range lines {
+		out = append(out, Edit{Op: ops[line[0]], Text: line[1:]})
+	}
+
+	return out
+}
+
+func TestLines(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want []Edit
+	}{
+		{"equal", "abc", "abc", edits(" a", " b", " c")},
+		{"both empty", "", "", edits()},
+		{"insert into empty", "", "ab", edits("+a", "+b")},
+		{"delete everything", "ab", "", edits("-a", "-b")},
+		{"change in the middle", "abc", "aXc", edits(" a", "-b", "+X", " c")},
+		{"insert", "ac", "abc", edits(" a", "+b", " c")},
+		{"delete", "abc", "ac", edits(" a", "-b", " c")},
+		{"common lines kept", "axbycz", "abcd", edits(" a", "-x", " b", "-y", " c", "-z", "+d")},
+		{"moved line", "abcd", "bcda", edits("-a", " b", " c", " d", "+a")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Lines(lines(c.a), lines(c.b)); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Lines(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+			}
+		})
+	}
+}
+
+func TestHunks(t *testing.T) {
+	cases := []struct {
+		name    string
+		a, b    string
+		context int
+		headers []string
+	}{
+		{"no changes", "abc", "abc", 3, nil},
+		{"single change", "abcdefghij", "abcdeXghij", 1, []string{"@@ -5,3 +5,3 @@"}},
+		{"change at the start", "abcdef", "Xbcdef", 2, []string{"@@ -1,3 +1,3 @@"}},
+		{"change at the end", "abcdef", "abcdeX", 2, []string{"@@ -4,3 +4,3 @@"}},
+		{"nearby changes are joined", "abcdefghij", "aXcdeXghij", 2, []string{"@@ -1,8 +1,8 @@"}},
+		{"gap of twice the context is joined", "abcdefghij", "aXcdYfghij", 1, []string{"@@ -1,6 +1,6 @@"}},
+		{"longer gaps are split", "abcdefghij", "aXcdeYghij", 1, []string{"@@ -1,3 +1,3 @@", "@@ -5,3 +5,3 @@"}},
+		{"distant changes are split", "abcdefghijkl", "aXcdefghijYl", 1, []string{"@@ -1,3 +1,3 @@", "@@ -10,3 +10,3 @@"}},
+		{"insert only", "abcd", "abXcd", 1, []string{"@@ -2,2 +2,3 @@"}},
+		{"delete only", "abcd", "abd", 0, []string{"@@ -3,1 +2,0 @@"}},
+		{"insert into empty", "", "ab", 3, []string{"@@ -0,0 +1,2 @@"}},
+		{"delete everything", "ab", "", 3, []string{"@@ -1,2 +0,0 @@"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var headers []string
+			for _, hunk := range Hunks(Lines(lines(c.a), lines(c.b)), c.context) {
+				headers = append(headers, hunk.Header())
+			}
+
+			if !reflect.DeepEqual(headers, c.headers) {
+				t.Errorf("Hunks() headers = %v, want %v", headers, c.headers)
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"same", "a\nb\n", "a\nb\n", ""},
+		{
+			name: "change",
+			a:    "a\nb\nc\nd\n",
+			b:    "a\nB\nc\nd\n",
+			want: "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "new file",
+			a:    "",
+			b:    "one\n",
+			want: "--- a/f\n+++ b/f\n@@ -0,0 +1,1 @@\n+one\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Unified("a/f", "b/f", c.a, c.b, 1); got != c.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, c.want)
+			}
+		})
+	}
+}
@@ -12,6 +12,14 @@ const (
 	OpenAiOrg        = "OPEN_AI_ORG"
 	MaxRequestTokens = "MAX_REQUEST_TOKENS"
 	MaxPrevMesgs     = "MAX_PREV_MSGS"
+	SendKey          = "SEND_KEY"
+	Model            = "MODEL"
+	VisionModel      = "VISION_MODEL"
+	McpConfig        = "MCP_CONFIG"
+	BaseURL          = "OPEN_AI_BASE_URL"
+	Database         = "DB_PATH"
+
+	CredentialBackend = "CREDENTIAL_BACKEND"
 )
 
 var loaded bool
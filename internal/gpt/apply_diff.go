@@ -0,0 +1,269 @@
+package gpt
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/indeedhat/term-gpt/internal/patch"
+	"github.com/indeedhat/term-gpt/internal/theme"
+)
+
+const (
+	applyStagePick = iota
+	applyStagePreview
+)
+
+// applyDiffForm is the modal used to pick a diff from the chat and apply it to the working tree
+//
+// The diff is dry run against the files on disk when it is picked, the preview shows which hunks
+// apply along with the diff itself and nothing is written until the user confirms
+type applyDiffForm struct {
+	blocks []codeBlock
+	cursor int
+	stage  int
+
+	files   []patch.File
+	results []patch.Result
+	preview string
+	// scroll is the number of preview lines scrolled past
+	scroll int
+	err    string
+}
+
+// newApplyDiffForm sets up the form with the most recent diff selected
+func newApplyDiffForm(blocks []codeBlock) applyDiffForm {
+	return applyDiffForm{blocks: blocks, cursor: len(blocks) - 1}
+}
+
+// View renders the current stage of the form, height is the number of lines available
+func (f applyDiffForm) View(styles theme.Styles, height int) string {
+	var (
+		buf  strings.Builder
+		rows = max(1, height-modalChrome)
+	)
+
+	switch f.stage {
+	case applyStagePick:
+		buf.WriteString("Apply Diff\n\n")
+
+		start := max(0, min(f.cursor-rows/2, len(f.blocks)-rows))
+		for i := start; i < len(f.blocks) && i < start+rows; i++ {
+			cursor := "  "
+			if i == f.cursor {
+				cursor = styles.Accent.Render("> ")
+			}
+
+			buf.WriteString(fmt.Sprintf("%s%2d. %s\n", cursor, i+1, diffSummary(f.blocks[i])))
+		}
+
+		buf.WriteString("\nenter: dry run • esc: cancel • up/down: move\n")
+	case applyStagePreview:
+		buf.WriteString(fmt.Sprintf("Apply diff %d\n\n", f.cursor+1))
+		buf.WriteString(pageLines(f.preview, f.scroll, rows) + "\n")
+
+		if patch.Clean(f.results) {
+			buf.WriteString("\nenter/y: apply • esc: back • up/down: scroll\n")
+		} else {
+			buf.WriteString("\nf: apply anyway, skipping failed hunks • esc: back • up/down: scroll\n")
+		}
+	}
+
+	if f.err != "" {
+		buf.WriteString("\n" + styles.Error.Render(f.err) + "\n")
+	}
+
+	return buf.String()
+}
+
+// move changes the highlighted diff in the picker
+func (f *applyDiffForm) move(delta int) {
+	f.cursor = max(0, min(len(f.blocks)-1, f.cursor+delta))
+}
+
+// dryRun parses the highlighted diff and checks it against the files on disk
+func (f *applyDiffForm) dryRun(styles theme.Styles) {
+	f.err = ""
+
+	files, err := patch.Parse(f.blocks[f.cursor].Code)
+	if err != nil {
+		f.err = err.Error()
+		return
+	}
+
+	f.files = files
+	f.results = patch.Check(files)
+	f.preview = renderPatchReport(styles, f.results) + "\n\n" + renderDiff(styles, f.blocks[f.cursor].Code)
+	f.scroll = 0
+	f.stage = applyStagePreview
+}
+
+// openApplyDiff opens the apply diff modal listing the diffs in the replies of the active chat
+// in selection mode only the diffs from the selected message are listed
+func (m *Model) openApplyDiff() {
+	blocks := m.activeChat.codeBlocks()
+	if msg, ok := m.selectedMessage(); ok && m.focus == elemSelect {
+		blocks = parseCodeBlocks(msg.Content)
+	}
+
+	var diffs []codeBlock
+	for _, block := range blocks {
+		if isDiffBlock(block) {
+			diffs = append(diffs, block)
+		}
+	}
+
+	if len(diffs) == 0 {
+		m.notice = "There are no diffs to apply"
+		return
+	}
+
+	m.applyDiff = newApplyDiffForm(diffs)
+	m.focusElement(elemApplyDiff)
+}
+
+// handleApplyDiffKey handles the key presses for the apply diff modal
+func (m *Model) handleApplyDiffKey(msg tea.KeyMsg) {
+	f := &m.applyDiff
+
+	if msg.String() == "esc" {
+		if f.stage == applyStagePick {
+			m.focusElement(elemTextArea)
+		} else {
+			f.err = ""
+			f.stage = applyStagePick
+		}
+
+		return
+	}
+
+	switch f.stage {
+	case applyStagePick:
+		switch msg.String() {
+		case "up", "k":
+			f.move(-1)
+		case "down", "j":
+			f.move(1)
+		case "enter":
+			f.dryRun(m.styles)
+		}
+	case applyStagePreview:
+		switch msg.String() {
+		case "up", "k":
+			f.scroll = scrollLines(f.preview, f.scroll, -1)
+		case "down", "j":
+			f.scroll = scrollLines(f.preview, f.scroll, 1)
+		case "enter", "y":
+			if patch.Clean(f.results) {
+				m.writePatch(false)
+			}
+		case "f":
+			m.writePatch(true)
+		}
+	}
+}
+
+// writePatch applies the picked diff to the working tree
+// the dry run is repeated first in case the files have changed since the preview was shown, unless
+// force is set nothing is written if hunks no longer apply and the preview is updated instead
+func (m *Model) writePatch(force bool) {
+	f := &m.applyDiff
+
+	results := patch.Check(f.files)
+	if !force && !patch.Clean(results) {
+		f.results = results
+		f.preview = renderPatchReport(m.styles, results) + "\n\n" + renderDiff(m.styles, f.blocks[f.cursor].Code)
+		f.err = "The files have changed since the dry run, check the preview again"
+		return
+	}
+
+	if err := patch.Write(results); err != nil {
+		f.err = err.Error()
+		return
+	}
+
+	var applied, failed, files int
+	for _, r := range results {
+		applied += r.Applied()
+		failed += r.Failed()
+		if r.Applied() > 0 {
+			files++
+		}
+	}
+
+	m.notice = fmt.Sprintf("Applied %d hunks to %d files", applied, files)
+	if failed > 0 {
+		m.notice += fmt.Sprintf(", %d hunks failed", failed)
+	}
+
+	m.focusElement(elemTextArea)
+}
+
+// renderPatchReport lists the outcome of the dry run for each file and hunk
+func renderPatchReport(styles theme.Styles, results []patch.Result) string {
+	var lines []string
+
+	for _, r := range results {
+		path := r.File.Path()
+		switch {
+		case r.File.IsNew():
+			path += " (new file)"
+		case r.File.IsDelete():
+			path += " (deleted)"
+		}
+
+		if r.Err != nil {
+			lines = append(lines, styles.Fail.Render(fmt.Sprintf("✗ %s: %s", path, r.Err)))
+			continue
+		}
+
+		status := styles.Ok.Render("✓")
+		if r.Failed() > 0 {
+			status = styles.Fail.Render("✗")
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %d of %d hunks apply", status, path, r.Applied(), len(r.Hunks)))
+
+		for i, hunk := range r.Hunks {
+			switch {
+			case hunk.Err != nil:
+				lines = append(lines, styles.Fail.Render(fmt.Sprintf("    hunk %d failed: %s", i+1, hunk.Err)))
+			case hunk.Fuzzy:
+				lines = append(lines, styles.Warn.Render(fmt.Sprintf("    hunk %d applies at line %d ignoring whitespace", i+1, hunk.Line)))
+			case hunk.Offset != 0:
+				lines = append(lines, styles.Warn.Render(fmt.Sprintf("    hunk %d applies at line %d (offset %d)", i+1, hunk.Line, hunk.Offset)))
+			}
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// isDiffBlock reports if a code block contains a unified diff
+func isDiffBlock(block codeBlock) bool {
+	switch block.Language {
+	case "diff", "patch":
+		return true
+	default:
+		return patch.Looks(block.Code)
+	}
+}
+
+// diffSummary describes the diff in the picker by the files it changes
+func diffSummary(block codeBlock) string {
+	files, err := patch.Parse(block.Code)
+	if err != nil {
+		return "(" + err.Error() + ")"
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file.Path())
+	}
+
+	summary := strings.Join(paths, ", ")
+	if len([]rune(summary)) > maxSummaryLen {
+		summary = string([]rune(summary)[:maxSummaryLen]) + "…"
+	}
+
+	return summary
+}
@@ -0,0 +1,153 @@
+package gpt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/filepicker"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/indeedhat/term-gpt/internal/store"
+)
+
+const (
+	// attachCommand is typed into the textarea to attach a file to the next prompt
+	attachCommand = "/attach"
+	// maxAttachmentSize is the largest file (in bytes) that can be attached to a prompt
+	maxAttachmentSize = 512 * 1024
+)
+
+// languages maps file extensions (and some well known file names) to markdown fence languages
+var languages = map[string]string{
+	".c":         "c",
+	".h":         "c",
+	".cpp":       "cpp",
+	".cs":        "csharp",
+	".css":       "css",
+	".go":        "go",
+	".html":      "html",
+	".java":      "java",
+	".js":        "javascript",
+	".json":      "json",
+	".kt":        "kotlin",
+	".lua":       "lua",
+	".md":        "markdown",
+	".php":       "php",
+	".py":        "python",
+	".rb":        "ruby",
+	".rs":        "rust",
+	".sh":        "bash",
+	".sql":       "sql",
+	".toml":      "toml",
+	".ts":        "typescript",
+	".tsx":       "tsx",
+	".yaml":      "yaml",
+	".yml":       "yaml",
+	"Dockerfile": "dockerfile",
+	"Makefile":   "make",
+}
+
+// readAttachment loads a file from disk ready to be attached to a message
+func readAttachment(path string) (store.Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return store.Attachment{}, err
+	}
+
+	if info.IsDir() {
+		return store.Attachment{}, fmt.Errorf("%s is a directory", path)
+	}
+
+	if info.Size() > maxAttachmentSize {
+		return store.Attachment{}, fmt.Errorf("%s is too large to attach", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return store.Attachment{}, err
+	}
+
+	if !utf8.Valid(data) {
+		return store.Attachment{}, errors.New("binary files cannot be attached")
+	}
+
+	return store.Attachment{
+		Path:     path,
+		Language: fileLanguage(path),
+		Content:  string(data),
+	}, nil
+}
+
+// fileLanguage guesses the markdown fence language for the given file
+func fileLanguage(path string) string {
+	base := filepath.Base(path)
+	if lang, ok := languages[base]; ok {
+		return lang
+	}
+
+	ext := filepath.Ext(base)
+	if lang, ok := languages[ext]; ok {
+		return lang
+	}
+
+	return strings.TrimPrefix(ext, ".")
+}
+
+// fenceAttachment wraps the attachment content in a fenced code block labeled with its path
+func fenceAttachment(a store.Attachment) string {
+	return a.Path + "\n" + fenced(a.Content, a.Language)
+}
+
+// fenced wraps content in a markdown fenced code block
+func fenced(content, language string) string {
+	// the fence needs to be longer than any backtick run in the content itself
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+
+	return fmt.Sprintf("%s%s\n%s\n%s", fence, language, strings.TrimRight(content, "\n"), fence)
+}
+
+// messageContent builds the full content of a message as it is to be sent to the openai API
+// with all its attachments included
+func messageContent(msg store.Message) string {
+	if len(msg.Attachments) == 0 {
+		return msg.Content
+	}
+
+	parts := make([]string, 0, len(msg.Attachments)+1)
+	if msg.Content != "" {
+		parts = append(parts, msg.Content)
+	}
+
+	for _, a := range msg.Attachments {
+		parts = append(parts, fenceAttachment(a))
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// attachmentSummary renders the collapsed one line description of an attachment
+func attachmentSummary(a store.Attachment) string {
+	lines := strings.Count(strings.TrimRight(a.Content, "\n"), "\n") + 1
+	if a.Language == "" {
+		return fmt.Sprintf("📎 %s (%d lines)", a.Path, lines)
+	}
+
+	return fmt.Sprintf("📎 %s (%s, %d lines)", a.Path, a.Language, lines)
+}
+
+// newFilePicker sets up the file picker used to find files to attach
+func newFilePicker() filepicker.Model {
+	picker := filepicker.New()
+	picker.AutoHeight = false
+	picker.ShowHidden = true
+	// esc is used to close the picker so it cannot also be used to go up a directory
+	picker.KeyMap.Back = key.NewBinding(key.WithKeys("h", "backspace", "left"))
+
+	return picker
+}
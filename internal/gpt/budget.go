@@ -0,0 +1,97 @@
+package gpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/indeedhat/term-gpt/internal/usage"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// tokensPerMessage covers the role and formatting tokens the API adds to each message
+	tokensPerMessage = 4
+	// tokensPerImage is the cost of a high detail 1024x1024 image, the most common case
+	tokensPerImage = 765
+	// defaultCompletionEstimate is used as the completion size when max_request_tokens is unlimited
+	defaultCompletionEstimate = 1000
+)
+
+// budgetConfirm holds a request that would exceed the budget while the user decides whether to
+// send it anyway
+type budgetConfirm struct {
+	chatId int
+	req    openai.ChatCompletionRequest
+	reason string
+}
+
+// checkBudget compares the estimated cost of the request against the spend so far today and
+// this month, nil is returned if the request is within budget
+func checkBudget(m *Model, req openai.ChatCompletionRequest) error {
+	if !m.conf.Budget.Enabled() {
+		return nil
+	}
+
+	now := time.Now()
+
+	return m.conf.Budget.Check(
+		m.usageRepo.SpendSince(usage.StartOfDay(now)),
+		m.usageRepo.SpendSince(usage.StartOfMonth(now)),
+		estimateCost(m, req),
+	)
+}
+
+// estimateCost gives a rough estimate of the cost of a request before it is sent
+// the completion is assumed to use all of the max_request_tokens
+func estimateCost(m *Model, req openai.ChatCompletionRequest) float64 {
+	completionTokens := req.MaxTokens
+	if completionTokens == 0 {
+		completionTokens = defaultCompletionEstimate
+	}
+
+	return m.conf.Pricing.Cost(req.Model, estimatePromptTokens(req), completionTokens)
+}
+
+// estimatePromptTokens gives a rough estimate of the number of tokens in the messages and tool
+// definitions of a request
+func estimatePromptTokens(req openai.ChatCompletionRequest) int {
+	var promptTokens int
+
+	for _, msg := range req.Messages {
+		promptTokens += tokensPerMessage + usage.EstimateTokens(msg.Content)
+
+		for _, part := range msg.MultiContent {
+			if part.Type == openai.ChatMessagePartTypeImageURL {
+				promptTokens += tokensPerImage
+			} else {
+				promptTokens += usage.EstimateTokens(part.Text)
+			}
+		}
+
+		for _, call := range msg.ToolCalls {
+			promptTokens += usage.EstimateTokens(call.Function.Name + call.Function.Arguments)
+		}
+	}
+
+	if len(req.Tools) > 0 {
+		if defs, err := json.Marshal(req.Tools); err == nil {
+			promptTokens += usage.EstimateTokens(string(defs))
+		}
+	}
+
+	return promptTokens
+}
+
+// appendNotice adds a message from term-gpt to the chat, notices are shown to the user but never
+// sent to the model
+func (m *Model) appendNotice(history *store.ChatHistory, format string, args ...any) {
+	history.ChatLog = append(history.ChatLog, store.Message{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: fmt.Sprintf(format, args...),
+		Status:  store.StatusNotice,
+	})
+
+	chatChanged(m, history)
+}
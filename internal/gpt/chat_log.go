@@ -2,28 +2,75 @@ package gpt
 
 import (
 	"bytes"
+	"fmt"
+	"hash/fnv"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/indeedhat/term-gpt/internal/theme"
 	"github.com/sashabaranov/go-openai"
 )
 
 type chatLog struct {
-	history   *store.ChatHistory
-	nameStyle lipgloss.Style
-	markdown  *glamour.TermRenderer
+	history *store.ChatHistory
+	// markdown and styles are built from the theme the chat is drawn with, see setTheme
+	markdown *glamour.TermRenderer
+	styles   theme.Styles
+
+	// selected is the index of the message highlighted in selection mode, -1 when not selecting
+	selected int
+	// cache holds the rendered messages so only new or changed messages go through glamour
+	cache *renderCache
+}
+
+// renderKey identifies a rendered message, the hash covers every field of the message that
+// affects how it is rendered
+type renderKey struct {
+	hash  uint64
+	width int
+}
+
+// renderCache holds messages that have already been rendered at the current width
+//
+// The markdown is rendered by glamour at a fixed width so it is kept separately by message hash,
+// that way a resize only has to re-wrap each message rather than render it from scratch
+type renderCache struct {
+	width    int
+	entries  map[renderKey]string
+	markdown map[uint64]string
+}
+
+// newRenderCache creates an empty render cache
+func newRenderCache() *renderCache {
+	return &renderCache{
+		entries:  make(map[renderKey]string),
+		markdown: make(map[uint64]string),
+	}
 }
 
-// newChatLog helper for setting up the chat log instance
-func newChatLog() chatLog {
-	md, _ := glamour.NewTermRenderer(glamour.WithAutoStyle())
+// resize throws away the messages wrapped to the old width
+func (r *renderCache) resize(width int) {
+	r.width = width
+	r.entries = make(map[renderKey]string)
+}
+
+// clear empties the cache
+func (r *renderCache) clear() {
+	r.resize(r.width)
+	r.markdown = make(map[uint64]string)
+}
 
+// newChatLog helper for setting up the chat log instance drawn with the theme
+func newChatLog(t theme.Theme) chatLog {
 	return chatLog{
-		nameStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("5")),
-		markdown:  md,
+		markdown: newMarkdownRenderer(t),
+		styles:   t.Styles(),
+		selected: -1,
+		cache:    newRenderCache(),
 		history: &store.ChatHistory{
 			ChatHistoryMeta: store.ChatHistoryMeta{
 				ChatTitle: "New Chat",
@@ -33,30 +80,154 @@ func newChatLog() chatLog {
 	}
 }
 
-// Render the chat log to a string
-func (c chatLog) Render() string {
-	var buf bytes.Buffer
+// setTheme switches the chat over to the theme, the cached messages were rendered with the old
+// colours so they are thrown away
+func (c *chatLog) setTheme(t theme.Theme) {
+	c.markdown = newMarkdownRenderer(t)
+	c.styles = t.Styles()
+	c.cache.clear()
+}
+
+// Render the chat log to a string wrapped to width
+func (c chatLog) Render(width int) string {
+	return strings.Join(c.Blocks(width), "\n")
+}
 
-	for _, msg := range c.history.ChatLog {
-		name := "You: "
-		if msg.Role == openai.ChatMessageRoleSystem {
-			name = "GPT: "
+// Blocks renders each message in the chat log wrapped to width, the blocks are in the same order
+// as the messages in the log and are joined with a newline
+//
+// Rendered messages are cached so only messages that are new or have changed since the last call
+// are rendered, changing the width throws away the cache
+func (c chatLog) Blocks(width int) []string {
+	if width != c.cache.width {
+		c.cache.resize(width)
+	}
+
+	blocks := make([]string, 0, len(c.history.ChatLog))
+	for i, msg := range c.history.ChatLog {
+		if i != c.selected {
+			blocks = append(blocks, c.renderCached(msg, width))
+			continue
 		}
 
-		var content string
-		if c.markdown != nil {
-			content, _ = c.markdown.Render(msg.Content)
+		// the selection border takes up some of the width
+		block := c.renderCached(msg, width-c.styles.Selected.GetHorizontalFrameSize())
+		blocks = append(blocks, c.styles.Selected.Render(strings.TrimRight(block, "\n"))+"\n")
+	}
+
+	return blocks
+}
+
+// renderCached renders the message wrapped to width, using the cached copy if there is one
+func (c chatLog) renderCached(msg store.Message, width int) string {
+	key := renderKey{hash: messageHash(msg), width: width}
+	if block, ok := c.cache.entries[key]; ok {
+		return block
+	}
+
+	rendered, ok := c.cache.markdown[key.hash]
+	if !ok {
+		rendered = c.renderMessage(msg)
+		c.cache.markdown[key.hash] = rendered
+	}
+
+	// the trailing newline of each block is shared with the first line of the next
+	block := lipgloss.NewStyle().Width(width).Render(strings.TrimSuffix(rendered, "\n"))
+	c.cache.entries[key] = block
+
+	return block
+}
+
+// messageHash hashes all of the fields used by renderMessage
+func messageHash(msg store.Message) uint64 {
+	h := fnv.New64a()
+
+	write := func(fields ...string) {
+		for _, field := range fields {
+			h.Write([]byte(field))
+			h.Write([]byte{0})
 		}
-		if content == "" {
-			content = msg.Content
+	}
+
+	write(msg.Role, msg.Status, msg.Name, msg.Content)
+	for _, call := range msg.ToolCalls {
+		write(call.Function.Name, call.Function.Arguments)
+	}
+	for _, a := range msg.Attachments {
+		write(attachmentSummary(a))
+	}
+	for _, img := range msg.Images {
+		write(imageSummary(img))
+	}
+
+	return h.Sum64()
+}
+
+// renderMessage renders a single message from the chat log
+func (c chatLog) renderMessage(msg store.Message) string {
+	var (
+		buf      bytes.Buffer
+		name     = c.styles.Name.Render("You: ")
+		markdown = numberCodeBlocks(msg.Content)
+	)
+
+	switch msg.Status {
+	case store.StatusNotice:
+		return c.styles.Notice.Render("Notice: "+msg.Content) + "\n\n"
+	case store.StatusError:
+		return c.styles.Error.Render("Error: "+msg.Content) + "\n\n"
+	}
+
+	switch msg.Role {
+	case openai.ChatMessageRoleSystem, openai.ChatMessageRoleAssistant:
+		name = c.styles.Name.Render("GPT: ")
+		if len(msg.ToolCalls) > 0 {
+			name = c.styles.Tool.Render("Tool calls: ")
+			markdown = strings.TrimSpace(msg.Content+"\n\n") + renderToolCalls(msg.ToolCalls)
 		}
+	case openai.ChatMessageRoleTool:
+		name = c.styles.Tool.Render(fmt.Sprintf("Tool (%s): ", msg.Name))
+		markdown = renderToolResult(msg)
+	}
+
+	var content string
+	if c.markdown != nil {
+		content, _ = c.markdown.Render(markdown)
+	}
+	if content == "" {
+		content = markdown
+	}
 
-		buf.WriteString(c.nameStyle.Render(name) + content + "\n\n")
+	buf.WriteString(name + content)
+	for _, a := range msg.Attachments {
+		buf.WriteString("  " + c.styles.Attachment.Render(attachmentSummary(a)) + "\n")
+	}
+	for _, img := range msg.Images {
+		buf.WriteString("  " + c.styles.Attachment.Render(imageSummary(img)) + "\n")
 	}
+	if msg.Status == store.StatusFailed {
+		buf.WriteString("  " + c.styles.Error.Render("✗ failed") + "\n")
+	}
+	buf.WriteString("\n\n")
 
 	return buf.String()
 }
 
+// codeBlocks extracts the fenced code blocks from all of the replies in the chat log
+func (c chatLog) codeBlocks() []codeBlock {
+	var blocks []codeBlock
+
+	for _, msg := range c.history.ChatLog {
+		if msg.Role != openai.ChatMessageRoleAssistant || msg.Status != "" {
+			continue
+		}
+
+		blocks = append(blocks, parseCodeBlocks(msg.Content)...)
+	}
+
+	return blocks
+}
+
 // historyList converts a []store.ChatHistoryMeta slice into a []list.Item slice
 // because go interfaces don't play nicely with slices
 func historyList(history []store.ChatHistoryMeta) []list.Item {
@@ -68,3 +239,20 @@ func historyList(history []store.ChatHistoryMeta) []list.Item {
 
 	return l
 }
+
+// usage totals up the tokens used and cost of all the replies in the chat log
+func (c chatLog) usage() store.Usage {
+	var total store.Usage
+
+	for _, msg := range c.history.ChatLog {
+		if msg.Usage == nil {
+			continue
+		}
+
+		total.PromptTokens += msg.Usage.PromptTokens
+		total.CompletionTokens += msg.Usage.CompletionTokens
+		total.Cost += msg.Usage.Cost
+	}
+
+	return total
+}
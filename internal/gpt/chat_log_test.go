@@ -0,0 +1,178 @@
+package gpt
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/indeedhat/term-gpt/internal/theme"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// benchMessages is the length of the chat used by the benchmarks
+	benchMessages = 300
+	benchWidth    = 100
+)
+
+// syntheticLog builds a chat of n messages alternating between prompts and markdown replies
+func syntheticLog(n int) store.ChatLog {
+	log := make(store.ChatLog, 0, n)
+
+	for i := 0; i < n; i++ {
+		log = append(log, syntheticMessage(i))
+	}
+
+	return log
+}
+
+// syntheticMessage builds the ith message of a synthetic chat, replies include a list and a code
+// block so they take the full path through glamour
+func syntheticMessage(i int) store.Message {
+	if i%2 == 0 {
+		return store.Message{
+			Role:    openai.ChatMessageRoleUser,
+			Content: fmt.Sprintf("Question %d: how do I read a file in go?", i),
+		}
+	}
+
+	return store.Message{
+		Role: openai.ChatMessageRoleAssistant,
+		Content: fmt.Sprintf("Answer %d, use **os.ReadFile**:\n\n"+
+			"- it reads the whole file\n- it returns an error if the file is missing\n\n"+
+			"```go\ndata, err := os.ReadFile(\"file_%d.txt\")\nif err != nil {\n\treturn err\n}\n```\n", i, i),
+	}
+}
+
+// newSyntheticChat creates a chat log holding a copy of log
+func newSyntheticChat(log store.ChatLog) chatLog {
+	c := newChatLog(theme.Default())
+	c.history.ChatLog = slices.Clone(log)
+
+	return c
+}
+
+func TestBlocksCachedMatchesUncached(t *testing.T) {
+	highContrast, _ := theme.Find(theme.Builtin(), "high-contrast")
+
+	cases := []struct {
+		name string
+		// change is made to the chat after the cache has been warmed at benchWidth
+		change func(c *chatLog)
+		width  int
+		// keepsMarkdown is set if the markdown rendered before the change should be reused
+		keepsMarkdown bool
+		// renders is the number of messages that should go through glamour after the change
+		renders int
+		// clears is set if the change should empty the cache before the next render
+		clears bool
+		// theme is the theme the chat is drawn with after the change, the default if unset
+		theme theme.Theme
+	}{
+		{
+			name: "appended message",
+			change: func(c *chatLog) {
+				c.history.ChatLog = append(c.history.ChatLog, syntheticMessage(len(c.history.ChatLog)))
+			},
+			width:         benchWidth,
+			keepsMarkdown: true,
+			renders:       1,
+		},
+		{
+			name: "message changed in place",
+			// the last prompt and its reply are both marked as failed
+			change:  func(c *chatLog) { failTurn(c.history) },
+			width:   benchWidth,
+			renders: 2,
+		},
+		{
+			name:          "resize",
+			change:        func(c *chatLog) {},
+			width:         benchWidth + 20,
+			keepsMarkdown: true,
+		},
+		{
+			name:    "theme change",
+			change:  func(c *chatLog) { c.setTheme(highContrast) },
+			width:   benchWidth,
+			clears:  true,
+			renders: 20,
+			theme:   highContrast,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cached := newSyntheticChat(syntheticLog(20))
+			before := cached.Render(benchWidth)
+			markdown := maps.Clone(cached.cache.markdown)
+
+			c.change(&cached)
+			if c.clears && (len(cached.cache.entries) > 0 || len(cached.cache.markdown) > 0) {
+				t.Fatal("cache was not cleared")
+			}
+
+			got := cached.Render(c.width)
+
+			for hash, rendered := range markdown {
+				if now, ok := cached.cache.markdown[hash]; c.keepsMarkdown && (!ok || now != rendered) {
+					t.Fatal("markdown rendered before the change was not reused")
+				}
+			}
+			if !c.keepsMarkdown && !c.clears && got == before {
+				t.Fatal("render did not change")
+			}
+
+			renders := len(cached.cache.markdown) - len(markdown)
+			if c.clears {
+				renders = len(cached.cache.markdown)
+			}
+			if renders != c.renders {
+				t.Fatalf("%d messages were rendered, want %d", renders, c.renders)
+			}
+
+			uncached := newSyntheticChat(cached.history.ChatLog)
+			if c.theme.Name != "" {
+				uncached.setTheme(c.theme)
+			}
+			if want := uncached.Render(c.width); got != want {
+				t.Fatalf("cached render does not match uncached render\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	log := syntheticLog(benchMessages)
+
+	b.Run("cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c := newSyntheticChat(log)
+			c.Blocks(benchWidth)
+		}
+	})
+
+	b.Run("append", func(b *testing.B) {
+		c := newSyntheticChat(log)
+		c.Blocks(benchWidth)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			// a new message each time so it is never already in the cache
+			c.history.ChatLog = append(c.history.ChatLog[:benchMessages], syntheticMessage(benchMessages+i))
+			c.Blocks(benchWidth)
+		}
+	})
+
+	b.Run("resize", func(b *testing.B) {
+		c := newSyntheticChat(log)
+		c.Blocks(benchWidth)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			c.Blocks(benchWidth + 1 + i%2)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package gpt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// codeBlock is a fenced code block found in a message
+type codeBlock struct {
+	// Language is the first word of the fence info string
+	Language string
+	// Info is the full info string following the opening fence
+	Info string
+	Code string
+
+	// start and end are the line numbers of the opening and closing fences
+	start int
+	end   int
+}
+
+// parseCodeBlocks finds all of the fenced code blocks in a markdown document
+// an unclosed fence runs to the end of the document as it does in commonmark
+func parseCodeBlocks(markdown string) []codeBlock {
+	var (
+		blocks []codeBlock
+		lines  = strings.Split(markdown, "\n")
+	)
+
+	for i := 0; i < len(lines); i++ {
+		fence, info, ok := openingFence(lines[i])
+		if !ok {
+			continue
+		}
+
+		block := codeBlock{Info: info, start: i, end: len(lines)}
+		if fields := strings.Fields(info); len(fields) > 0 {
+			block.Language = fields[0]
+		}
+
+		var code []string
+		for i++; i < len(lines); i++ {
+			if isClosingFence(lines[i], fence) {
+				block.end = i
+				break
+			}
+
+			code = append(code, lines[i])
+		}
+
+		block.Code = strings.Join(code, "\n")
+		blocks = append(blocks, block)
+	}
+
+	return blocks
+}
+
+// Filename looks for a file name in the info string, such as ```go main.go or ```python title="app.py"
+// a language that looks like a path (```main.go) is also used as the file name
+func (b codeBlock) Filename() string {
+	fields := strings.Fields(b.Info)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	if strings.ContainsAny(fields[0], "./") {
+		return fields[0]
+	}
+
+	for _, field := range fields[1:] {
+		if key, val, ok := strings.Cut(field, "="); ok {
+			switch key {
+			case "title", "file", "filename", "path":
+				return strings.Trim(val, `"'`)
+			}
+
+			continue
+		}
+
+		return strings.Trim(field, `"'`)
+	}
+
+	return ""
+}
+
+// numberCodeBlocks adds a [n] label above each fenced code block so they can be referred to by number
+func numberCodeBlocks(markdown string) string {
+	blocks := parseCodeBlocks(markdown)
+	if len(blocks) == 0 {
+		return markdown
+	}
+
+	lines := strings.Split(markdown, "\n")
+	numbered := make([]string, 0, len(lines)+len(blocks)*2)
+
+	next := 0
+	for i, line := range lines {
+		if next < len(blocks) && blocks[next].start == i {
+			next++
+			numbered = append(numbered, fmt.Sprintf("`[%d]`", next), "")
+		}
+
+		numbered = append(numbered, line)
+	}
+
+	return strings.Join(numbered, "\n")
+}
+
+// openingFence checks if the line opens a fenced code block, returning the fence and info string
+func openingFence(line string) (fence, info string, ok bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return "", "", false
+	}
+
+	for _, char := range []string{"`", "~"} {
+		n := len(trimmed) - len(strings.TrimLeft(trimmed, char))
+		if n < 3 {
+			continue
+		}
+
+		info = strings.TrimSpace(trimmed[n:])
+		// backtick fences can't have backticks in their info string
+		if char == "`" && strings.Contains(info, "`") {
+			return "", "", false
+		}
+
+		return trimmed[:n], info, true
+	}
+
+	return "", "", false
+}
+
+// isClosingFence checks if the line closes a code block opened with fence
+func isClosingFence(line, fence string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return false
+	}
+
+	trimmed = strings.TrimRight(trimmed, " \t")
+	char := fence[:1]
+
+	return len(trimmed) >= len(fence) && strings.Trim(trimmed, char) == ""
+}
@@ -0,0 +1,41 @@
+package gpt
+
+import (
+	"strings"
+
+	"github.com/indeedhat/term-gpt/internal/theme"
+)
+
+// pageLines returns up to rows lines of text starting from the scroll position
+func pageLines(text string, scroll, rows int) string {
+	lines := strings.Split(text, "\n")
+	scroll = min(scroll, len(lines))
+
+	return strings.Join(lines[scroll:min(len(lines), scroll+rows)], "\n")
+}
+
+// scrollLines moves the scroll position through text by delta lines, keeping at least one line visible
+func scrollLines(text string, scroll, delta int) int {
+	lines := strings.Count(text, "\n") + 1
+	return max(0, min(lines-1, scroll+delta))
+}
+
+// renderDiff colours the lines of a unified diff
+func renderDiff(styles theme.Styles, text string) string {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = styles.DiffFile.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = styles.DiffHunk.Render(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = styles.DiffAdd.Render(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = styles.DiffDelete.Render(line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,58 @@
+package gpt
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const defaultEditor = "vi"
+
+// openEditor writes the current draft to a temp file and opens it in the users $EDITOR
+// once the editor exits the file contents are sent back to the model as an editorResultMsg
+func openEditor(draft string) tea.Cmd {
+	file, err := os.CreateTemp("", "term-gpt-*.md")
+	if err != nil {
+		return editorResult("", err)
+	}
+
+	path := file.Name()
+	_, err = file.WriteString(draft)
+	file.Close()
+	if err != nil {
+		os.Remove(path)
+		return editorResult("", err)
+	}
+
+	// $EDITOR may contain arguments (code --wait) so it needs splitting
+	args := strings.Fields(os.Getenv("EDITOR"))
+	if len(args) == 0 {
+		args = []string{defaultEditor}
+	}
+
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(path)
+
+		if err != nil {
+			return editorResultMsg{err: err}
+		}
+
+		data, err := os.ReadFile(path)
+
+		return editorResultMsg{
+			content: strings.TrimRight(string(data), "\n"),
+			err:     err,
+		}
+	})
+}
+
+// editorResult wraps an editorResultMsg in a tea.Cmd for convenience
+func editorResult(content string, err error) tea.Cmd {
+	return func() tea.Msg {
+		return editorResultMsg{content: content, err: err}
+	}
+}
@@ -3,30 +3,45 @@ package gpt
 import (
 	"context"
 	"fmt"
-	"math"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/charmbracelet/bubbles/filepicker"
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/indeedhat/term-gpt/internal/config"
+	"github.com/indeedhat/term-gpt/internal/keymap"
+	"github.com/indeedhat/term-gpt/internal/mcp"
 	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/indeedhat/term-gpt/internal/theme"
+	"github.com/indeedhat/term-gpt/internal/tools"
 	"github.com/sashabaranov/go-openai"
 	"golang.org/x/term"
 )
 
 const (
-	textAreaHeight   = 3
-	chatHistoryWidth = 0.25
+	textAreaHeight    = 3
+	maxTextAreaHeight = 10
 
 	// screen realestate used by ui flavour
 	borderCols         = 4
 	chatVpPaddingWidth = 4
 )
 
-var colorMain = lipgloss.Color("5")
+// welcomeMessage fills the chat viewport until a chat is opened
+const welcomeMessage = "Welcom to term-gpt!"
+
+// historyTitle is the title of the chat history list, it is followed by the tag filter if one is set
+const historyTitle = "Chat History"
 
 // focusedElement represents the ui element that the user is currently interacting with and
 // defines the keyboard behaviour for that element
@@ -37,6 +52,14 @@ type focusedElement string
 const (
 	elemTextArea    focusedElement = "ta"
 	elemChatHistory focusedElement = "ch"
+	elemFilePicker  focusedElement = "fp"
+	elemServers     focusedElement = "mcp"
+	elemSettings    focusedElement = "set"
+	elemHelp        focusedElement = "help"
+	elemSelect      focusedElement = "sel"
+	elemSaveCode    focusedElement = "save"
+	elemApplyDiff   focusedElement = "diff"
+	elemTheme       focusedElement = "theme"
 )
 
 type Model struct {
@@ -50,12 +73,45 @@ type Model struct {
 	textarea textarea.Model
 	// spinner to show when we are waiting for a response from ChatGPT
 	spinner spinner.Model
+	// filePicker is used to browse for files to attach to the next prompt
+	filePicker filepicker.Model
+	// serversVp displays the connected MCP servers and their tools in place of the chat viewport
+	serversVp viewport.Model
+	// settings is the modal used to edit the sampling parameters of the active chat
+	settings settingsForm
+	// help renders the key bindings in the help overlay
+	help help.Model
+	// saveCode is the modal used to save a code block from the chat to a file
+	saveCode saveCodeForm
+	// applyDiff is the modal used to apply a diff from the chat to the working tree
+	applyDiff applyDiffForm
+	// themePicker is the modal used to switch themes
+	themePicker themePicker
+
+	// attachments holds the files that will be sent along with the next prompt
+	attachments []store.Attachment
+	// images holds the images that will be sent along with the next prompt
+	images []store.Image
+	// notice is a one off message displayed above the textarea until the next key press
+	notice string
 
 	// Chat concains the message history for this activeChat session
 	activeChat chatLog
+	// msgOffsets holds the line of the chat viewport that each message in the active chat starts on
+	msgOffsets []int
 
+	// conf holds the app settings
+	conf *config.Config
 	// client holds the openai client for
 	client *openai.Client
+	// tools is the registry of tools that the model is allowed to call
+	tools *tools.Registry
+	// confirms holds the tool calls waiting on the user for approval, they are answered in order
+	confirms []toolConfirmMsg
+	// overBudget holds a request that would exceed the budget while waiting on the user to confirm it
+	overBudget *budgetConfirm
+	// servers contains the configured MCP servers whose tools are included in the registry
+	servers []*mcp.Server
 
 	// ctx is the shared context sent along with web requests an can be used to gracefully close
 	// connections early if the app closes while a web request is running
@@ -63,64 +119,117 @@ type Model struct {
 	// cancel stores the cancel function that can be used to close the ctx
 	cancel context.CancelFunc
 
-	// waiting tracks if we are currently waiting for a response from the openai API or not
-	waiting bool
+	// pending tracks the chats that are currently waiting for a response from the openai API
+	pending map[int]*pendingRequest
+	// queue holds the prompts waiting to be sent once the current request has finished, by chat id
+	queue map[int]store.ChatLog
+	// unread tracks the chats that have received a reply since they were last viewed
+	unread map[int]bool
+
+	// listLoaded is the number of chats paged into the history list from the database
+	listLoaded int
+	// listDone is set once every chat has been paged into the history list
+	listDone bool
+	// listFilter is the history filter that chats were last searched for in the database
+	listFilter string
+	// listTag and listStarred limit the history list to the chats with the tag and/or starred chats
+	listTag     string
+	listStarred bool
+	// listCmd holds the commands returned by changes to the history list, it is run at the end of
+	// the update
+	listCmd tea.Cmd
+
+	// status is the status bar at the top of the screen, it is only changed by the status messages
+	status statusBar
+	// statusSent is the state of the status bar once the status messages already sent are applied
+	statusSent statusBar
+	// statusKey is the state of the active chat the last context estimate was requested for
+	statusKey statusKey
 
 	// windowHeight stores the height of the terminal from the previous frame
 	windowHeight int
 	// windowWidth stores the width of the terminal from the previous frame
 	windowWidth int
+	// layout holds the size of each pane as worked out by computeLayout
+	layout layout
+	// sidebar is set while the chat history sidebar is shown
+	sidebar bool
+	// sidebarWidth is the fraction of the window width given to the sidebar
+	sidebarWidth float64
+
+	// theme is the theme the ui is drawn with and styles the lipgloss styles built from it, they are
+	// changed with setTheme
+	theme  theme.Theme
+	styles theme.Styles
 
 	// focus tracks the element the user is currently focusing
 	focus focusedElement
+	// keys holds the key bindings, built from the preset and bindings in the config
+	keys keymap.KeyMap
 
 	// program stores the bubble tea program reference
 	program *tea.Program
 	// repo is the storage repository that persists chat data between sessions
 	repo store.ChatHistoryRepo
+	// usageRepo is the storage repository that records the tokens used by each request
+	usageRepo store.UsageRepo
 }
 
 // New creates a new model for the bubble tea tui
-func New(repo store.ChatHistoryRepo, client *openai.Client) *Model {
+func New(
+	conf *config.Config,
+	repo store.ChatHistoryRepo,
+	usageRepo store.UsageRepo,
+	client *openai.Client,
+	registry *tools.Registry,
+	servers []*mcp.Server,
+) *Model {
 	// query environment
 	width, height, _ := term.GetSize(int(os.Stdout.Fd()))
 
+	// the theme has already been validated when the config was loaded
+	themes, _ := theme.All(conf.ThemeDir)
+	uiTheme, ok := theme.Find(themes, conf.Theme)
+	if !ok {
+		uiTheme = theme.Default()
+	}
+
 	// load data
-	activeChat := newChatLog()
-	chatHistory := append(
-		[]store.ChatHistoryMeta{activeChat.history.ChatHistoryMeta},
-		repo.List()...,
-	)
+	activeChat := newChatLog(uiTheme)
+	// the rest of the chats are paged in once the model is set up
+	chatHistory := []store.ChatHistoryMeta{activeChat.history.ChatHistoryMeta}
 
 	// Textarea setup
 	txtArea := textarea.New()
 	txtArea.Placeholder = "Write your message..."
-	txtArea.CharLimit = 1000
+	txtArea.CharLimit = 0
+	txtArea.MaxHeight = 0
 
 	txtArea.Focus()
-	txtArea.SetWidth(width)
 	txtArea.SetHeight(textAreaHeight)
 
 	txtArea.FocusedStyle.CursorLine = lipgloss.NewStyle()
-	txtArea.FocusedStyle.Prompt.Foreground(colorMain)
 	txtArea.ShowLineNumbers = false
-	txtArea.KeyMap.InsertNewline.SetEnabled(false)
+	// the key config has already been validated when the config was loaded
+	keys, _ := keymap.New(conf.Keys.Preset, conf.SendKey, conf.Keys.Bindings)
+	txtArea.KeyMap.InsertNewline.SetKeys(keys.Newline.Keys()...)
+
+	helpModel := help.New()
+	helpModel.ShowAll = true
 
 	requestSpinner := spinner.New()
-	requestSpinner.Style = lipgloss.NewStyle().Foreground(colorMain)
 	requestSpinner.Spinner = spinner.Dot
 
+	// the pane sizes are all set by applyLayout once the model is set up
 	// Chat History Viewport
-	historyWidth := int(math.Floor(float64(width) * chatHistoryWidth))
-	chatHistoryList := list.New(historyList(chatHistory), list.NewDefaultDelegate(), historyWidth-2, height-textAreaHeight*2-2)
-	chatHistoryList.Title = "Chat History"
+	chatHistoryList := list.New(historyList(chatHistory), list.NewDefaultDelegate(), 0, 0)
+	chatHistoryList.Title = historyTitle
 
-	chatHistoryVp := viewport.New(historyWidth, height-textAreaHeight*2)
+	chatHistoryVp := viewport.New(0, 0)
 	chatHistoryVp.Style = lipgloss.NewStyle().BorderStyle(lipgloss.RoundedBorder())
-	chatHistoryVp.SetContent(lipgloss.NewStyle().Width(width).Render(" "))
 
 	// Chat Viewport
-	chatVp := viewport.New(width-historyWidth, height-textAreaHeight*2)
+	chatVp := viewport.New(0, 0)
 	chatVp.Style = lipgloss.NewStyle().BorderStyle(lipgloss.RoundedBorder())
 	chatVp.Style.Padding(1, 2)
 
@@ -133,48 +242,103 @@ func New(repo store.ChatHistoryRepo, client *openai.Client) *Model {
 		chatHistoryList: chatHistoryList,
 		chatVp:          chatVp,
 		spinner:         requestSpinner,
+		filePicker:      newFilePicker(),
+		help:            helpModel,
+		keys:            keys,
+		conf:            conf,
 		client:          client,
+		tools:           registry,
+		servers:         servers,
 		ctx:             ctx,
 		cancel:          cancel,
 		windowWidth:     width,
 		windowHeight:    height,
+		sidebar:         conf.Layout.Sidebar,
+		sidebarWidth:    conf.Layout.SidebarWidth,
 		focus:           elemTextArea,
 		activeChat:      activeChat,
 		repo:            repo,
+		usageRepo:       usageRepo,
+		pending:         make(map[int]*pendingRequest),
+		queue:           make(map[int]store.ChatLog),
+		unread:          make(map[int]bool),
+		// no chat has an id of -1 so the first sync always estimates the context
+		statusKey: statusKey{chatId: -1},
 	}
 
-	m.updateViewportContent("Welcom to term-gpt!")
+	m.setTheme(uiTheme)
+
+	loadMoreChats(m)
+	m.applyLayout()
+	m.updateViewportContent(welcomeMessage)
 
 	return m
 }
 
 // Init implements tea.Model.
 func (m *Model) Init() tea.Cmd {
-	return tea.Batch(textarea.Blink, m.spinner.Tick)
+	return tea.Batch(textarea.Blink, m.spinner.Tick, m.syncStatus())
 }
 
 // Update implements tea.Model.
-func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+func (m *Model) Update(msg tea.Msg) (_ tea.Model, cmd tea.Cmd) {
+	// changes made to the history list while it is filtered need it to run the filter again and
+	// any changes to the state shown in the status bar are sent on to it
+	defer func() {
+		cmd = tea.Batch(cmd, m.listCmd, m.syncStatus())
+		m.listCmd = nil
+	}()
+
+	// a pending tool confirmation captures all key presses so they don't end up in the textarea
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && len(m.confirms) > 0 {
+		return m, m.handleConfirmKey(keyMsg)
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.overBudget != nil {
+		return m, m.handleBudgetKey(keyMsg)
+	}
+
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		if cmd, handled := m.handleKeyMsg(keyMsg); handled {
+			m.resizeTextarea()
+			return m, cmd
+		}
+	}
+
 	uiCmd := m.updateUiComponents(msg)
 
 	switch msg := msg.(type) {
 	case *tea.Program:
 		m.program = msg
 	case tea.WindowSizeMsg:
-		m.handleWindowResize()
-	case spinMsg:
-		m.waiting = true
+		m.handleWindowResize(msg)
+	case statusChatMsg, statusContextMsg, statusRequestMsg, statusFocusMsg:
+		m.status.Update(msg)
+	case retryMsg:
+		if req := m.pending[msg.chatId]; req != nil {
+			req.retry = &msg
+		}
 	case chatResultMsg:
-		m.handleChatResultMsg(msg)
-	case tea.KeyMsg:
-		if cmd := m.handleKeyMsg(msg); cmd != nil {
-			return m, cmd
+		uiCmd = tea.Batch(uiCmd, m.handleChatResultMsg(msg))
+	case chatEntryMsg:
+		m.handleChatEntryMsg(msg)
+	case toolConfirmMsg:
+		m.confirms = append(m.confirms, msg)
+	case editorResultMsg:
+		if msg.err != nil {
+			m.notice = fmt.Sprintf("Error: %s", msg.err)
+		} else {
+			m.textarea.SetValue(msg.content)
 		}
+		uiCmd = tea.Batch(uiCmd, m.restoreMouse())
+	case clipboardMsg:
+		uiCmd = tea.Batch(uiCmd, m.handleClipboardMsg(msg))
 	case error:
 		m.cancel()
 		return m, tea.Quit
 	}
 
+	m.resizeTextarea()
+
 	return m, uiCmd
 }
 
@@ -182,21 +346,116 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 // It is called to generate the current frame for the application
 func (m *Model) View() string {
 	var textarea string
-	if m.waiting {
-		textarea = fmt.Sprintf(" %s GPT is thinking...\n\n", m.spinner.View())
+	if len(m.confirms) > 0 {
+		textarea = fmt.Sprintf(
+			" Allow GPT to call %s with %s%s? [y/n]\n\n",
+			m.confirms[0].call.Function.Name,
+			m.confirms[0].call.Function.Arguments,
+			m.chatLabel(m.confirms[0].chatId),
+		)
+	} else if m.overBudget != nil {
+		textarea = fmt.Sprintf(
+			" %s%s. Send anyway? [y/n]\n\n",
+			m.overBudget.reason,
+			m.chatLabel(m.overBudget.chatId),
+		)
 	} else {
 		textarea = m.textarea.View()
 	}
 
 	m.chatHistory.SetContent(m.chatHistoryList.View())
 
+	chatVp := m.chatVp
+	switch m.focus {
+	case elemFilePicker:
+		chatVp.SetContent(m.filePicker.View())
+		chatVp.GotoTop()
+	case elemServers:
+		chatVp = m.serversVp
+	case elemSettings:
+		chatVp.SetContent(m.settings.View(m.styles))
+		chatVp.GotoTop()
+	case elemSaveCode:
+		chatVp.SetContent(m.saveCode.View(m.styles, chatVp.Height-chatVp.Style.GetVerticalFrameSize()))
+		chatVp.GotoTop()
+	case elemApplyDiff:
+		chatVp.SetContent(m.applyDiff.View(m.styles, chatVp.Height-chatVp.Style.GetVerticalFrameSize()))
+		chatVp.GotoTop()
+	case elemTheme:
+		chatVp.SetContent(m.themePicker.View(m.styles, chatVp.Height-chatVp.Style.GetVerticalFrameSize()))
+		chatVp.GotoTop()
+	case elemHelp:
+		m.help.Width = chatVp.Width - chatVpPaddingWidth
+		chatVp.SetContent("Key Bindings\n\n" + m.help.View(m.keys) + "\n\nesc: close")
+		chatVp.GotoTop()
+	}
+
+	var panes []string
+	if m.layout.showChat {
+		panes = append(panes, chatVp.View())
+	}
+	if m.layout.showHistory {
+		panes = append(panes, m.chatHistory.View())
+	}
+
 	return fmt.Sprintf(
-		"\n%s\n\n%s\n\n",
-		lipgloss.JoinHorizontal(lipgloss.Top, m.chatVp.View(), m.chatHistory.View()),
+		"%s\n%s\n%s\n%s\n\n",
+		m.status.View(m.styles, m.windowWidth),
+		lipgloss.JoinHorizontal(lipgloss.Top, panes...),
+		m.inputInfo(),
 		textarea,
 	)
 }
 
+// inputInfo renders the line displayed between the chat panes and the textarea
+// this is either the current notice or the request state and list of pending attachments
+func (m *Model) inputInfo() string {
+	if m.notice != "" {
+		return " " + m.notice
+	}
+
+	var parts []string
+	if m.pending[m.activeChat.history.Id] != nil {
+		parts = append(parts, m.requestState())
+	}
+
+	if len(m.attachments) > 0 || len(m.images) > 0 {
+		names := make([]string, 0, len(m.attachments)+len(m.images))
+		for _, a := range m.attachments {
+			names = append(names, filepath.Base(a.Path))
+		}
+		for _, img := range m.images {
+			names = append(names, filepath.Base(img.Path))
+		}
+
+		parts = append(parts, "📎 "+strings.Join(names, ", "))
+	}
+
+	if len(parts) == 0 && m.canResend() {
+		parts = append(parts, m.keys.Resend.Help().Key+": resend failed message")
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return " " + strings.Join(parts, " • ")
+}
+
+// requestState describes the in flight request and the number of prompts queued behind it
+func (m *Model) requestState() string {
+	state := "GPT is thinking..."
+	if retry := m.pending[m.activeChat.history.Id].retry; retry != nil {
+		state = retry.countdown()
+	}
+
+	if n := m.queued(); n > 0 {
+		state += fmt.Sprintf(" (%d queued)", n)
+	}
+
+	return m.spinner.View() + " " + state
+}
+
 // updateUiComponents handles passing the tea.Msg to all the update methods of the active ui elements
 // in order to update their state
 func (m *Model) updateUiComponents(msg tea.Msg) tea.Cmd {
@@ -211,19 +470,36 @@ func (m *Model) updateUiComponents(msg tea.Msg) tea.Cmd {
 	switch m.focus {
 	case elemTextArea:
 		m.textarea, taCmd = m.textarea.Update(msg)
+	case elemFilePicker:
+		m.filePicker, taCmd = m.filePicker.Update(msg)
+
+		if ok, path := m.filePicker.DidSelectFile(msg); ok {
+			m.attachFile(path)
+			m.focusElement(elemTextArea)
+		}
+	case elemServers:
+		m.serversVp, taCmd = m.serversVp.Update(msg)
+	case elemSettings:
+		taCmd = m.settings.Update(msg)
+	case elemSaveCode:
+		taCmd = m.saveCode.Update(msg)
 	case elemChatHistory:
 		curIdx := m.chatHistoryList.Index()
 		m.chatHistoryList, chLiCmd = m.chatHistoryList.Update(msg)
 
-		// i suspect i might end up with a race condition problem here when sending gpt
-		// requests, if it happens i may need to add a mutex
 		if m.chatHistoryList.Index() != curIdx {
 			loadChat(m)
-			m.updateViewportContent(m.activeChat.Render())
+			m.renderChat()
 		}
+
+		pageChats(m)
+		searchChats(m)
 	}
 
-	// m.chatVp, vpCmd = m.chatVp.Update(msg)
+	// the chat viewport only gets the mouse wheel, its own key bindings clash with the textarea
+	if mouse, ok := msg.(tea.MouseMsg); ok && m.focus != elemServers && m.layout.showChat && mouse.X < m.layout.chatWidth {
+		m.chatVp, vpCmd = m.chatVp.Update(msg)
+	}
 	m.chatHistory, chCmd = m.chatHistory.Update(msg)
 	m.spinner, spinCmd = m.spinner.Update(msg)
 
@@ -232,115 +508,554 @@ func (m *Model) updateUiComponents(msg tea.Msg) tea.Cmd {
 
 // handleChatResultMsg takes the chat response from the open ai API and inserts it into the
 // chat log
-func (m *Model) handleChatResultMsg(msg chatResultMsg) {
-	m.waiting = false
-	if msg.err != nil {
-		msg.message = fmt.Sprintf("Error: %s", msg.err.Error())
+func (m *Model) handleChatResultMsg(msg chatResultMsg) tea.Cmd {
+	delete(m.pending, msg.chatId)
+
+	history := findChat(m, msg.chatId)
+	if history == nil {
+		refreshBadges(m, msg.chatId)
+		return m.dispatchQueued()
+	}
+
+	if msg.blocked != nil {
+		failTurn(history)
+		m.appendNotice(history, "Request blocked: %s", msg.blocked)
+
+		return m.dispatchQueued()
 	}
 
-	m.activeChat.history.ChatLog = append(m.activeChat.history.ChatLog, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleSystem,
+	reply := store.Message{
+		Role:    openai.ChatMessageRoleAssistant,
 		Content: msg.message,
-	})
+		Usage:   msg.usage,
+	}
+	if msg.err != nil {
+		failTurn(history)
+		reply.Content = msg.err.Error()
+		reply.Status = store.StatusError
+	}
+
+	history.ChatLog = append(history.ChatLog, reply)
+	if history != m.activeChat.history {
+		m.unread[history.Id] = true
+	}
+
+	chatChanged(m, history)
+	if err := recordUsage(m, history.Id, msg.usage); err != nil {
+		usageFailed(m, history, err)
+	}
+
+	return m.dispatchQueued()
+}
+
+// handleChatEntryMsg inserts an intermediate entry from an in progress request into the chat log
+func (m *Model) handleChatEntryMsg(msg chatEntryMsg) {
+	if req := m.pending[msg.chatId]; req != nil {
+		req.retry = nil
+	}
+
+	history := findChat(m, msg.chatId)
+	if history == nil {
+		return
+	}
+
+	history.ChatLog = append(history.ChatLog, msg.message)
+
+	chatChanged(m, history)
+	if err := recordUsage(m, history.Id, msg.message.Usage); err != nil {
+		usageFailed(m, history, err)
+	}
+}
+
+// handleConfirmKey answers the pending tool confirmation
+func (m *Model) handleConfirmKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c":
+		m.cancel()
+		return tea.Quit
+	case "y", "Y":
+		m.confirms[0].reply <- true
+	case "n", "N", "esc":
+		m.confirms[0].reply <- false
+	default:
+		return nil
+	}
+
+	m.confirms = m.confirms[1:]
+
+	return nil
+}
+
+// handleBudgetKey answers the pending over budget confirmation
+func (m *Model) handleBudgetKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c":
+		m.cancel()
+		return tea.Quit
+	case "y", "Y":
+		overBudget := m.overBudget
+		m.overBudget = nil
+
+		return m.startRequest(overBudget.chatId, overBudget.req)
+	case "n", "N", "esc":
+		overBudget := m.overBudget
+		m.overBudget = nil
+
+		if history := findChat(m, overBudget.chatId); history != nil {
+			failTurn(history)
+			m.appendNotice(history, "Request cancelled: %s", overBudget.reason)
+		}
+
+		return m.dispatchQueued()
+	}
+
+	return nil
+}
+
+// chatLabel names the chat for prompts that may be raised by a chat other than the active one
+func (m *Model) chatLabel(chatId int) string {
+	if chatId == m.activeChat.history.Id {
+		return ""
+	}
 
-	saveChat(m)
-	updateChatList(m)
+	for _, item := range m.chatHistoryList.Items() {
+		if meta, ok := item.(store.ChatHistoryMeta); ok && meta.Id == chatId {
+			return fmt.Sprintf(" (in %q)", meta.ChatTitle)
+		}
+	}
+
+	return ""
+}
 
-	m.updateViewportContent(m.activeChat.Render())
+// addListCmd queues up a command returned by a change to the history list
+func (m *Model) addListCmd(cmd tea.Cmd) {
+	m.listCmd = tea.Batch(m.listCmd, cmd)
 }
 
 // focusElement switches the pane focus to the indicated element
 func (m *Model) focusElement(elem focusedElement) {
 	m.textarea.Blur()
-	m.chatHistory.Style.BorderForeground(lipgloss.NoColor{})
+	m.chatHistory.Style.BorderForeground(m.styles.BorderColor)
+	m.chatVp.Style.BorderForeground(m.styles.BorderColor)
+
+	if m.focus == elemSelect && elem != elemSelect {
+		m.activeChat.selected = -1
+		m.renderChat()
+	}
 
 	switch elem {
 	case elemTextArea:
 		m.textarea.Focus()
 	case elemChatHistory:
-		m.chatHistory.Style.BorderForeground(colorMain)
+		m.chatHistory.Style.BorderForeground(m.styles.AccentColor)
+	case elemSelect:
+		m.chatVp.Style.BorderForeground(m.styles.AccentColor)
 	}
 
 	m.focus = elem
+	// focusing a hidden sidebar shows it
+	m.applyLayout()
 }
 
-// handleKeyMsg handles the side effects of any defined tea.KeyMsg key presses
-func (m *Model) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
-	switch msg.Type {
-	case tea.KeyCtrlC:
+// handleKeyMsg handles the side effects of any key presses bound in the key map
+// handled is false if the key press should be passed on to the focused ui element instead
+func (m *Model) handleKeyMsg(msg tea.KeyMsg) (cmd tea.Cmd, handled bool) {
+	m.notice = ""
+
+	// printable keys belong to whatever is being typed into, apart from the help key which still
+	// opens the help overlay while the prompt is empty
+	if m.typing() && isPrintable(msg) && !m.helpOnEmptyPrompt(msg) {
+		return nil, false
+	}
+
+	if key.Matches(msg, m.keys.Quit) {
 		fmt.Println("Goodbye :)")
 		m.cancel()
-		return tea.Quit
+		return tea.Quit, true
+	}
+
+	// the history list handles its own keys while the filter is being edited, its jumps to the
+	// start and end also win over the chat bindings (g and G in the vim preset) while it is focused
+	if m.focus == elemChatHistory && (m.chatHistoryList.FilterState() == list.Filtering ||
+		key.Matches(msg, m.chatHistoryList.KeyMap.GoToStart, m.chatHistoryList.KeyMap.GoToEnd)) {
+		return nil, false
+	}
 
-		// scroll the chat window
-	case tea.KeyCtrlN:
+	switch m.focus {
+	case elemSettings:
+		switch msg.String() {
+		case "esc":
+			m.focusElement(elemTextArea)
+		case "enter":
+			m.saveSettings()
+		default:
+			return nil, false
+		}
+
+		return nil, true
+	case elemFilePicker, elemServers, elemHelp:
+		closeKey := msg.String() == "esc" ||
+			(m.focus == elemServers && key.Matches(msg, m.keys.Servers)) ||
+			(m.focus == elemHelp && key.Matches(msg, m.keys.Help))
+		if !closeKey {
+			return nil, false
+		}
+
+		m.focusElement(elemTextArea)
+		return nil, true
+	case elemSaveCode:
+		return m.handleSaveCodeKey(msg)
+	case elemApplyDiff:
+		m.handleApplyDiffKey(msg)
+		return nil, true
+	case elemTheme:
+		m.handleThemeKey(msg)
+		return nil, true
+	case elemSelect:
+		if cmd, handled := m.handleSelectKey(msg); handled {
+			return cmd, true
+		}
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Help):
+		m.focusElement(elemHelp)
+	case key.Matches(msg, m.keys.Settings):
+		m.settings = newSettingsForm(m.conf.Sampling, m.activeChat.history.Settings)
+		m.focusElement(elemSettings)
+
+		return textinput.Blink, true
+	case key.Matches(msg, m.keys.SelectMode):
+		m.enterSelectMode()
+	case key.Matches(msg, m.keys.SaveCode):
+		m.openSaveCode()
+	case key.Matches(msg, m.keys.ApplyDiff):
+		m.openApplyDiff()
+	case key.Matches(msg, m.keys.Theme):
+		m.openThemePicker()
+	case key.Matches(msg, m.keys.ToggleSidebar):
+		m.toggleSidebar()
+	case key.Matches(msg, m.keys.ShrinkSidebar):
+		m.resizeSidebar(-1)
+	case key.Matches(msg, m.keys.GrowSidebar):
+		m.resizeSidebar(1)
+	case key.Matches(msg, m.keys.Servers):
+		m.serversVp = m.chatVp
+		m.serversVp.SetContent(renderServers(m.styles, m.servers))
+		m.serversVp.GotoTop()
+		m.focusElement(elemServers)
+
+	// scroll the chat window
+	case key.Matches(msg, m.keys.ScrollDown):
 		m.chatVp.LineDown(1)
-		return textarea.Blink
-	case tea.KeyCtrlP:
+	case key.Matches(msg, m.keys.ScrollUp):
 		m.chatVp.LineUp(1)
-		return textarea.Blink
-
-	case tea.KeyTab:
+	case key.Matches(msg, m.keys.PageDown):
+		m.chatVp.ViewDown()
+	case key.Matches(msg, m.keys.PageUp):
+		m.chatVp.ViewUp()
+	case key.Matches(msg, m.keys.HalfPageDown):
+		m.chatVp.HalfViewDown()
+	case key.Matches(msg, m.keys.HalfPageUp):
+		m.chatVp.HalfViewUp()
+	case key.Matches(msg, m.keys.JumpPrev):
+		m.jumpMessage(-1)
+	case key.Matches(msg, m.keys.JumpNext):
+		m.jumpMessage(1)
+	case key.Matches(msg, m.keys.Top):
+		m.chatVp.GotoTop()
+	case key.Matches(msg, m.keys.Bottom):
+		m.chatVp.GotoBottom()
+
+	case key.Matches(msg, m.keys.Focus):
 		if m.focus == elemTextArea {
 			m.focusElement(elemChatHistory)
 		} else {
 			m.focusElement(elemTextArea)
 		}
 
-	case tea.KeyEnter:
-		if m.focus == elemChatHistory {
-			m.focusElement(elemTextArea)
-			return nil
-		}
+	case m.focus == elemTextArea && key.Matches(msg, m.keys.Send):
+		return m.sendPrompt(), true
+	case m.focus == elemTextArea && key.Matches(msg, m.keys.Editor):
+		return openEditor(m.textarea.Value()), true
+	case m.focus == elemTextArea && key.Matches(msg, m.keys.Resend):
+		return m.resendFailed(), true
 
-		m.activeChat.history.ChatLog = append(m.activeChat.history.ChatLog, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleUser,
-			Content: m.textarea.Value(),
-		})
+	case m.focus == elemChatHistory && key.Matches(msg, m.keys.Select):
+		m.focusElement(elemTextArea)
 
-		saveChat(m)
-		updateChatList(m)
+	default:
+		return nil, false
+	}
+
+	return nil, true
+}
 
+// typing reports if the focused element is currently taking text input
+func (m *Model) typing() bool {
+	switch m.focus {
+	case elemTextArea, elemSettings:
+		return true
+	case elemChatHistory:
+		return m.chatHistoryList.FilterState() == list.Filtering
+	case elemSaveCode:
+		return m.saveCode.stage == saveStagePath
+	default:
+		return false
+	}
+}
+
+// helpOnEmptyPrompt reports if the key press is the help key typed into an empty prompt, there is
+// nothing to lose by opening the help overlay so a printable help key (?) still works there
+func (m *Model) helpOnEmptyPrompt(msg tea.KeyMsg) bool {
+	return m.focus == elemTextArea && m.textarea.Value() == "" && key.Matches(msg, m.keys.Help)
+}
+
+// isPrintable reports if the key press would insert a character when typing
+func isPrintable(msg tea.KeyMsg) bool {
+	return (msg.Type == tea.KeyRunes && !msg.Alt) || msg.Type == tea.KeySpace
+}
+
+// sendPrompt adds the textarea content to the chat log and sends it off to the openai API
+func (m *Model) sendPrompt() tea.Cmd {
+	prompt := strings.TrimSpace(m.textarea.Value())
+	if prompt == attachCommand || strings.HasPrefix(prompt, attachCommand+" ") {
+		m.textarea.Reset()
+		return m.handleAttachCommand(strings.TrimSpace(strings.TrimPrefix(prompt, attachCommand)))
+	}
+	if m.handleChatCommand(prompt) {
 		m.textarea.Reset()
-		m.updateViewportContent(m.activeChat.Render())
+		return nil
+	}
 
-		go sendGptRequest(m)
+	if prompt == "" && len(m.attachments) == 0 && len(m.images) == 0 {
+		return nil
 	}
 
-	return nil
+	msg := store.Message{
+		Role:        openai.ChatMessageRoleUser,
+		Content:     m.textarea.Value(),
+		Attachments: m.attachments,
+		Images:      m.images,
+	}
+
+	m.attachments = nil
+	m.images = nil
+	m.textarea.Reset()
+	m.chatVp.GotoBottom()
+
+	// each chat can only have one request in flight at a time, anything sent in the mean time
+	// waits its turn
+	if m.busy(m.activeChat.history.Id) {
+		m.queuePrompt(msg)
+		return nil
+	}
+
+	return m.sendMessage(m.activeChat.history, msg)
 }
 
-// handleWindowResize updates the size of the windows containing elements based on the current
+// handleWindowResize updates the size of the windows containing elements based on the new
 // terminal window size
-func (m *Model) handleWindowResize() {
-	w, h, _ := term.GetSize(int(os.Stdout.Fd()))
-	if w == m.windowWidth && h == m.windowHeight {
+func (m *Model) handleWindowResize(msg tea.WindowSizeMsg) {
+	if msg.Width == m.windowWidth && msg.Height == m.windowHeight {
+		return
+	}
+
+	m.windowHeight = msg.Height
+	m.windowWidth = msg.Width
+	m.applyLayout()
+}
+
+// saveSettings applies the values from the settings form to the active chat
+func (m *Model) saveSettings() {
+	settings, err := m.settings.Sampling()
+	if err != nil {
+		m.settings.err = err.Error()
+		return
+	}
+
+	m.activeChat.history.Settings = settings
+
+	// new chats are not stored until the first message is sent
+	if m.activeChat.history.Id != 0 {
+		if err := m.repo.Update(m.activeChat.history); err != nil {
+			m.settings.err = err.Error()
+			return
+		}
+	}
+
+	m.focusElement(elemTextArea)
+}
+
+// handleAttachCommand attaches the file at the given path to the next prompt
+// if the path is empty or points to a directory then the file picker is opened instead
+func (m *Model) handleAttachCommand(path string) tea.Cmd {
+	if path != "" {
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			m.attachFile(path)
+			return nil
+		}
+	}
+
+	if path == "" {
+		path = "."
+	}
+
+	m.filePicker.CurrentDirectory = path
+	m.focusElement(elemFilePicker)
+
+	return m.filePicker.Init()
+}
+
+// attachFile reads the file at path and adds it to the pending attachments
+// images are attached by reference so they can be sent to vision models
+func (m *Model) attachFile(path string) {
+	if isImage(path) {
+		img, err := readImage(path)
+		if err != nil {
+			m.notice = fmt.Sprintf("Error: %s", err)
+			return
+		}
+
+		m.images = append(m.images, img)
 		return
 	}
 
-	m.windowWidth = h
-	m.windowWidth = w
+	attachment, err := readAttachment(path)
+	if err != nil {
+		m.notice = fmt.Sprintf("Error: %s", err)
+		return
+	}
 
-	historyWidth := int(math.Floor(float64(w) * chatHistoryWidth))
-	m.chatHistory.Width = historyWidth
-	m.chatHistory.Height = h - textAreaHeight*2
+	m.attachments = append(m.attachments, attachment)
+}
 
-	m.chatVp.Height = h - textAreaHeight*2
-	m.chatVp.Width = w - historyWidth
+// resizeTextarea grows the textarea to fit its content up to maxTextAreaHeight lines
+func (m *Model) resizeTextarea() {
+	height := m.textarea.LineCount()
+	if height < textAreaHeight {
+		height = textAreaHeight
+	} else if height > maxTextAreaHeight {
+		height = maxTextAreaHeight
+	}
 
-	m.textarea.SetWidth(w)
+	if height == m.textarea.Height() {
+		return
+	}
 
-	m.updateViewportContent(m.activeChat.Render())
+	m.textarea.SetHeight(height)
+	m.applyLayout()
 }
 
 // updateViewportContent fills the chat viewport with rendered messages constrained to the size
 // of the viewport
 func (m *Model) updateViewportContent(text string) {
-	historyWidth := int(math.Floor(float64(m.windowWidth) * chatHistoryWidth))
-	chatVpWidth := m.windowWidth - borderCols - chatVpPaddingWidth - historyWidth
-
-	m.chatVp.SetContent(lipgloss.NewStyle().Width(chatVpWidth).Render(text))
+	m.chatVp.SetContent(lipgloss.NewStyle().Width(m.chatContentWidth()).Render(text))
 	m.chatVp.GotoBottom()
 }
 
+// redrawChat renders the active chat again, a chat without any messages shows the welcome message
+// instead as the viewport is only as wide as its content
+func (m *Model) redrawChat() {
+	if len(m.activeChat.history.ChatLog) == 0 {
+		m.updateViewportContent(welcomeMessage)
+		return
+	}
+
+	m.renderChat()
+}
+
+// renderChat fills the chat viewport with the active chat, recording the line each message starts on
+//
+// A selected message is kept in view. Otherwise the view follows new content if it was already at
+// the bottom of the chat, if not it stays on the same message it was showing before
+func (m *Model) renderChat() {
+	follow := len(m.msgOffsets) == 0 || m.chatVp.AtBottom()
+	anchor, delta := m.topMessage()
+
+	var (
+		blocks = m.activeChat.Blocks(m.chatContentWidth())
+		offset int
+	)
+
+	m.msgOffsets = m.msgOffsets[:0]
+	for _, block := range blocks {
+		m.msgOffsets = append(m.msgOffsets, offset)
+		offset += lipgloss.Height(block)
+	}
+
+	m.chatVp.SetContent(strings.Join(blocks, "\n"))
+
+	switch {
+	case m.activeChat.selected >= 0 && m.activeChat.selected < len(m.msgOffsets):
+		m.scrollToMessage(m.activeChat.selected)
+	case follow || len(m.msgOffsets) == 0:
+		m.chatVp.GotoBottom()
+	default:
+		anchor = min(anchor, len(m.msgOffsets)-1)
+		m.chatVp.SetYOffset(m.msgOffsets[anchor] + delta)
+	}
+}
+
+// topMessage finds the message shown at the top of the chat viewport and how many of its lines
+// have been scrolled past
+func (m *Model) topMessage() (idx, delta int) {
+	idx = sort.SearchInts(m.msgOffsets, m.chatVp.YOffset+1) - 1
+	if idx < 0 {
+		return 0, 0
+	}
+
+	return idx, m.chatVp.YOffset - m.msgOffsets[idx]
+}
+
+// jumpMessage scrolls the chat so the start of the previous (delta < 0) or next message is at the
+// top of the viewport
+func (m *Model) jumpMessage(delta int) {
+	idx, offset := m.topMessage()
+
+	switch {
+	case delta < 0 && offset > 0:
+		// scrolled part way through a message, go back to its start first
+	case delta < 0:
+		idx--
+	default:
+		idx++
+	}
+
+	if idx < 0 {
+		m.chatVp.GotoTop()
+		return
+	}
+	if idx >= len(m.msgOffsets) {
+		m.chatVp.GotoBottom()
+		return
+	}
+
+	m.chatVp.SetYOffset(m.msgOffsets[idx])
+}
+
+// scrollToMessage scrolls the chat viewport just far enough to bring the message into view
+// messages taller than the viewport are shown from the top
+func (m *Model) scrollToMessage(i int) {
+	start := m.msgOffsets[i]
+	end := m.chatVp.TotalLineCount()
+	if i+1 < len(m.msgOffsets) {
+		end = m.msgOffsets[i+1]
+	}
+
+	visible := m.chatVp.Height - m.chatVp.Style.GetVerticalFrameSize()
+
+	switch {
+	case start < m.chatVp.YOffset:
+		m.chatVp.SetYOffset(start)
+	case end > m.chatVp.YOffset+visible:
+		m.chatVp.SetYOffset(min(start, end-visible))
+	}
+}
+
+// chatContentWidth is the width available to the chat inside the viewport borders and padding
+func (m *Model) chatContentWidth() int {
+	return m.layout.chatContentWidth()
+}
+
 var _ tea.Model = (*Model)(nil)
@@ -0,0 +1,102 @@
+package gpt
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/indeedhat/term-gpt/internal/theme"
+)
+
+// chatGroup is the heading a chat is listed under in the chat history
+type chatGroup int
+
+const (
+	// groupNone is used for the new chat entry which sits above all of the groups
+	groupNone chatGroup = iota
+	groupPinned
+	groupToday
+	groupYesterday
+	groupOlder
+)
+
+// groupNames are the headings shown above each group
+var groupNames = map[chatGroup]string{
+	groupPinned:    "Pinned",
+	groupToday:     "Today",
+	groupYesterday: "Yesterday",
+	groupOlder:     "Older",
+}
+
+// groupOf works out which group the chat belongs in, now is the time the days are counted from
+func groupOf(meta store.ChatHistoryMeta, now time.Time) chatGroup {
+	switch {
+	case meta.Id == 0:
+		return groupNone
+	case meta.Pinned:
+		return groupPinned
+	}
+
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+
+	switch {
+	case !meta.UpdatedAt.Before(today):
+		return groupToday
+	case !meta.UpdatedAt.Before(today.AddDate(0, 0, -1)):
+		return groupYesterday
+	default:
+		return groupOlder
+	}
+}
+
+// historyDelegate renders the chat history with a heading above the first chat of each group
+//
+// Every item is given an extra line in place of the spacing between items, the heading is drawn
+// on that line so the list can still page by a fixed item height
+type historyDelegate struct {
+	list.DefaultDelegate
+	styles theme.Styles
+}
+
+// Height implements list.ItemDelegate.
+func (d historyDelegate) Height() int {
+	return d.DefaultDelegate.Height() + 1
+}
+
+// Spacing implements list.ItemDelegate.
+func (d historyDelegate) Spacing() int {
+	return 0
+}
+
+// Render implements list.ItemDelegate.
+func (d historyDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	fmt.Fprintln(w, d.groupHeading(m, index, item))
+	d.DefaultDelegate.Render(w, m, index, item)
+}
+
+// groupHeading returns the heading to show above the item, it is empty unless the item is the first
+// of its group. The list is ordered by how well each chat matches while it is being filtered so no
+// headings are shown
+func (d historyDelegate) groupHeading(m list.Model, index int, item list.Item) string {
+	if m.FilterState() != list.Unfiltered {
+		return ""
+	}
+
+	var (
+		now   = time.Now()
+		group = groupOf(item.(store.ChatHistoryMeta), now)
+		items = m.VisibleItems()
+	)
+
+	if group == groupNone || (index > 0 && groupOf(items[index-1].(store.ChatHistoryMeta), now) == group) {
+		return ""
+	}
+
+	return lipgloss.NewStyle().PaddingLeft(2).Render(d.styles.Group.Render(groupNames[group]))
+}
+
+var _ list.ItemDelegate = (*historyDelegate)(nil)
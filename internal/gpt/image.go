@@ -0,0 +1,106 @@
+package gpt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxImageSize is the largest image (in bytes) that can be attached to a prompt
+const maxImageSize = 20 * 1024 * 1024
+
+// imageTypes maps the supported image extensions to their mime type
+var imageTypes = map[string]string{
+	".gif":  "image/gif",
+	".jpeg": "image/jpeg",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+}
+
+// isImage checks if the file at path is one of the supported image types
+func isImage(path string) bool {
+	_, ok := imageTypes[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// readImage checks the image at path can be sent to the openai API and reads its dimensions
+//
+// Only a reference to the image is stored, it is read again from disk each time it is sent
+func readImage(path string) (store.Image, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return store.Image{}, err
+	}
+
+	if info.Size() > maxImageSize {
+		return store.Image{}, fmt.Errorf("%s is too large to attach", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return store.Image{}, err
+	}
+	defer file.Close()
+
+	conf, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return store.Image{}, fmt.Errorf("failed to read image %s: %w", path, err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return store.Image{}, err
+	}
+
+	return store.Image{
+		Path:     abs,
+		MimeType: imageTypes[strings.ToLower(filepath.Ext(path))],
+		Width:    conf.Width,
+		Height:   conf.Height,
+	}, nil
+}
+
+// imagePart loads the image from disk and encodes it as a data url message part
+// if the image can no longer be read a text part explaining so is returned in its place
+func imagePart(img store.Image) openai.ChatMessagePart {
+	data, err := os.ReadFile(img.Path)
+	if err != nil {
+		return openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeText,
+			Text: fmt.Sprintf("[image %s is no longer available]", filepath.Base(img.Path)),
+		}
+	}
+
+	return openai.ChatMessagePart{
+		Type: openai.ChatMessagePartTypeImageURL,
+		ImageURL: &openai.ChatMessageImageURL{
+			URL:    fmt.Sprintf("data:%s;base64,%s", img.MimeType, base64.StdEncoding.EncodeToString(data)),
+			Detail: openai.ImageURLDetailAuto,
+		},
+	}
+}
+
+// imageSummary renders the placeholder displayed in the chat log in place of an image
+func imageSummary(img store.Image) string {
+	return fmt.Sprintf("🖼 %s (%dx%d)", filepath.Base(img.Path), img.Width, img.Height)
+}
+
+// hasImages checks if any of the messages in the log contain images
+func hasImages(log store.ChatLog) bool {
+	for _, msg := range log {
+		if len(msg.Images) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
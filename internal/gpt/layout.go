@@ -0,0 +1,114 @@
+package gpt
+
+import "math"
+
+const (
+	// chromeRows is the number of rows used by the status line, input info and the spacing below
+	// the textarea
+	chromeRows = 3
+	// sidebarStep is how much the sidebar width changes by with each grow/shrink key press
+	sidebarStep = 0.05
+	minSidebar  = 0.1
+	maxSidebar  = 0.6
+)
+
+// layout holds the size of each pane, it is worked out from the window size in one place by
+// computeLayout so the panes never disagree about how much room they have
+type layout struct {
+	// compact is set when the window is too narrow to show the chat and the sidebar side by side
+	compact bool
+	// showChat and showHistory are the panes that are currently drawn
+	showChat    bool
+	showHistory bool
+
+	chatWidth    int
+	historyWidth int
+	// paneHeight is the height of both the chat and history panes including their borders
+	paneHeight    int
+	textareaWidth int
+}
+
+// chatContentWidth is the width available to the chat inside the viewport borders and padding
+func (l layout) chatContentWidth() int {
+	return max(1, l.chatWidth-borderCols-chatVpPaddingWidth)
+}
+
+// computeLayout works out the size of each pane from the window size and the sidebar settings
+//
+// In compact mode the sidebar takes the place of the chat while it is focused, a hidden sidebar is
+// also shown while it has focus so it can still be used to switch chats
+func (m *Model) computeLayout() layout {
+	l := layout{
+		compact:       m.windowWidth < m.conf.Layout.CompactWidth,
+		showChat:      true,
+		showHistory:   m.sidebar || m.focus == elemChatHistory,
+		chatWidth:     m.windowWidth,
+		paneHeight:    max(1, m.windowHeight-chromeRows-m.textarea.Height()),
+		textareaWidth: m.windowWidth,
+	}
+
+	switch {
+	case l.compact:
+		// the chat keeps its full width while hidden so it doesn't have to be wrapped again each
+		// time the focus moves between the panes
+		l.showHistory = m.focus == elemChatHistory
+		l.showChat = !l.showHistory
+		l.historyWidth = m.windowWidth
+	case l.showHistory:
+		l.historyWidth = int(math.Floor(float64(m.windowWidth) * m.sidebarWidth))
+		l.chatWidth = m.windowWidth - l.historyWidth
+	}
+
+	return l
+}
+
+// applyLayout resizes every pane to match the current window size and sidebar settings
+// the chat is only rendered again if its size has changed
+func (m *Model) applyLayout() {
+	prev := m.layout
+	m.layout = m.computeLayout()
+	l := m.layout
+
+	m.chatVp.Width = l.chatWidth
+	m.chatVp.Height = l.paneHeight
+	m.chatHistory.Width = l.historyWidth
+	m.chatHistory.Height = l.paneHeight
+	m.chatHistoryList.SetSize(max(1, l.historyWidth-2), max(1, l.paneHeight-2))
+	m.filePicker.Height = max(1, l.paneHeight-4)
+	m.textarea.SetWidth(l.textareaWidth)
+
+	if l.chatWidth != prev.chatWidth || l.paneHeight != prev.paneHeight {
+		m.redrawChat()
+	}
+}
+
+// toggleSidebar hides or shows the chat history sidebar
+// in compact mode there is no room for both panes so the focus is moved between them instead
+func (m *Model) toggleSidebar() {
+	switch {
+	case m.layout.compact && m.focus == elemChatHistory:
+		m.focusElement(elemTextArea)
+	case m.layout.compact:
+		m.focusElement(elemChatHistory)
+	default:
+		m.sidebar = !m.sidebar
+		if !m.sidebar && m.focus == elemChatHistory {
+			m.focusElement(elemTextArea)
+		}
+	}
+
+	m.applyLayout()
+}
+
+// resizeSidebar changes the width of the sidebar by delta steps
+func (m *Model) resizeSidebar(delta int) {
+	if m.layout.compact || !m.layout.showHistory {
+		m.notice = "The sidebar can't be resized while it is hidden"
+		return
+	}
+
+	width := m.sidebarWidth + float64(delta)*sidebarStep
+	// rounding stops repeated steps from drifting away from the configured widths
+	m.sidebarWidth = max(minSidebar, min(maxSidebar, math.Round(width*100)/100))
+	m.applyLayout()
+}
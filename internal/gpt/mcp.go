@@ -0,0 +1,49 @@
+package gpt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/indeedhat/term-gpt/internal/mcp"
+	"github.com/indeedhat/term-gpt/internal/theme"
+)
+
+// renderServers renders the list of configured MCP servers along with the tools they expose
+func renderServers(styles theme.Styles, servers []*mcp.Server) string {
+	if len(servers) == 0 {
+		return "No MCP servers configured"
+	}
+
+	var buf strings.Builder
+	buf.WriteString("MCP Servers\n\n")
+
+	for _, server := range servers {
+		if !server.Connected() {
+			buf.WriteString(styles.Fail.Render("✗ "+server.Name) + "\n")
+			buf.WriteString(fmt.Sprintf("    %s\n\n", server.Err))
+			continue
+		}
+
+		buf.WriteString(styles.Ok.Render("✓ "+server.Name) + fmt.Sprintf(" (%d tools)\n", len(server.Tools)))
+		// tools that couldn't be registered
+		if server.Err != nil {
+			buf.WriteString(styles.Warn.Render("    "+strings.ReplaceAll(server.Err.Error(), "\n", "\n    ")) + "\n")
+		}
+		for _, tool := range server.Tools {
+			buf.WriteString("    " + tool.Name)
+			if tool.Description != "" {
+				buf.WriteString(" - " + firstLine(tool.Description))
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("\n")
+	}
+
+	return buf.String()
+}
+
+// firstLine returns the first line of a multi line string
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
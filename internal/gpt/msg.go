@@ -1,15 +1,59 @@
 package gpt
 
 import (
+	"fmt"
+	"time"
+
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/indeedhat/term-gpt/internal/retry"
+	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/sashabaranov/go-openai"
 )
 
 type chatResultMsg struct {
+	// chatId is the chat that the request was made for
+	chatId  int
 	err     error
 	message string
+	usage   *store.Usage
+	// blocked is set when a round of tool calls was stopped by the budget, it is added to the chat
+	// as a notice in place of the reply
+	blocked error
+}
+
+// chatEntryMsg adds an intermediate entry (such as a tool call) to the chat log while a request
+// is still in progress
+type chatEntryMsg struct {
+	chatId  int
+	message store.Message
+}
+
+// toolConfirmMsg asks the user to approve a tool call, the answer is sent back on the reply chan
+type toolConfirmMsg struct {
+	chatId int
+	call   openai.ToolCall
+	reply  chan bool
 }
 
-type spinMsg bool
+// retryMsg is sent while waiting to retry a failed request so a countdown can be shown
+type retryMsg struct {
+	chatId  int
+	kind    retry.Kind
+	attempt int
+	max     int
+	at      time.Time
+}
+
+type editorResultMsg struct {
+	err     error
+	content string
+}
+
+// clipboardMsg reports the outcome of copying to the clipboard
+type clipboardMsg struct {
+	what string
+	err  error
+}
 
 // windowResize wraps the windowResizeMsg Cmd forconvenience
 func windowResize(w, h int) tea.Cmd {
@@ -20,3 +64,13 @@ func windowResize(w, h int) tea.Cmd {
 		}
 	}
 }
+
+// countdown describes the pending retry for display in the spinner line
+func (r retryMsg) countdown() string {
+	wait := time.Until(r.at).Round(time.Second)
+	if wait < 0 {
+		wait = 0
+	}
+
+	return fmt.Sprintf("%s, retrying in %s (attempt %d/%d)", r.kind, wait, r.attempt, r.max)
+}
@@ -0,0 +1,108 @@
+package gpt
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/indeedhat/term-gpt/internal/usage"
+	"github.com/sashabaranov/go-openai"
+)
+
+// pendingRequest tracks the in flight request for a chat
+type pendingRequest struct {
+	// retry is set while waiting to retry a failed request
+	retry *retryMsg
+}
+
+// busy reports if the chat has a request in flight or is waiting on the user to confirm going
+// over budget
+func (m *Model) busy(chatId int) bool {
+	return m.pending[chatId] != nil || (m.overBudget != nil && m.overBudget.chatId == chatId)
+}
+
+// queuePrompt holds onto a prompt for the active chat until its current request has finished
+func (m *Model) queuePrompt(msg store.Message) {
+	id := m.activeChat.history.Id
+	m.queue[id] = append(m.queue[id], msg)
+}
+
+// queued returns the number of prompts waiting to be sent for the active chat
+func (m *Model) queued() int {
+	return len(m.queue[m.activeChat.history.Id])
+}
+
+// dispatchQueued sends the next queued prompt for every chat that is not already waiting on a reply
+func (m *Model) dispatchQueued() tea.Cmd {
+	var cmds []tea.Cmd
+
+	for id := range m.queue {
+		// only one over budget confirmation can be shown at a time
+		for len(m.queue[id]) > 0 && !m.busy(id) && m.overBudget == nil {
+			msg := m.queue[id][0]
+			m.queue[id] = m.queue[id][1:]
+
+			history := findChat(m, id)
+			if history == nil {
+				// the chat has gone away so there is nowhere to send the prompts
+				m.queue[id] = nil
+				break
+			}
+
+			cmds = append(cmds, m.sendMessage(history, msg))
+		}
+
+		if len(m.queue[id]) == 0 {
+			delete(m.queue, id)
+		}
+	}
+
+	return tea.Batch(cmds...)
+}
+
+// sendMessage adds the prompt to the chat and sends it off to the openai API
+//
+// Requests are tracked by the id of their chat so nothing is sent for a new chat that could not be
+// saved, the prompt is put back in the textarea to try again instead
+func (m *Model) sendMessage(history *store.ChatHistory, msg store.Message) tea.Cmd {
+	history.ChatLog = append(history.ChatLog, msg)
+	if err := chatChanged(m, history); err != nil && history.Id == 0 {
+		history.ChatLog = history.ChatLog[:len(history.ChatLog)-1]
+
+		if history == m.activeChat.history {
+			m.textarea.SetValue(msg.Content)
+			m.attachments, m.images = msg.Attachments, msg.Images
+			m.renderChat()
+		}
+
+		return nil
+	}
+
+	return m.dispatchRequest(history)
+}
+
+// dispatchRequest sends the chat off to the openai API as long as it is within budget
+func (m *Model) dispatchRequest(history *store.ChatHistory) tea.Cmd {
+	req := buildRequest(m, history)
+	if err := checkBudget(m, req); err != nil {
+		if m.conf.Budget.Action == usage.BudgetConfirm {
+			m.overBudget = &budgetConfirm{chatId: history.Id, req: req, reason: err.Error()}
+		} else {
+			failTurn(history)
+			m.appendNotice(history, "Request blocked: %s", err)
+		}
+
+		return nil
+	}
+
+	return m.startRequest(history.Id, req)
+}
+
+// startRequest marks the chat as waiting and returns the command that runs the request
+// the request runs in its own goroutine and its result is delivered back to the update loop
+func (m *Model) startRequest(chatId int, req openai.ChatCompletionRequest) tea.Cmd {
+	m.pending[chatId] = &pendingRequest{}
+	refreshBadges(m, chatId)
+
+	return func() tea.Msg {
+		return sendGptRequest(m, chatId, req)
+	}
+}
@@ -1,41 +1,233 @@
 package gpt
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"time"
 
-	"github.com/indeedhat/term-gpt/internal/env"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/indeedhat/term-gpt/internal/retry"
 	"github.com/indeedhat/term-gpt/internal/store"
 	"github.com/sashabaranov/go-openai"
 )
 
-// sendGptRequest sends off a completion request to the chat gpt api
-func sendGptRequest(m *Model) {
-	m.program.Send(spinMsg(true))
+// maxToolRounds limits the number of times the model can call tools before it must give a reply
+const maxToolRounds = 10
 
+// buildRequest builds the completion request for the chat
+func buildRequest(m *Model, history *store.ChatHistory) openai.ChatCompletionRequest {
 	var (
-		msgs     store.ChatLog
-		msgCount = len(m.activeChat.history.ChatLog)
-		maxMsgs  = env.GetInt(env.MaxPrevMesgs)
+		msgs     = contextMessages(history.ChatLog)
+		msgCount = len(msgs)
+		maxMsgs  = m.conf.MaxPrevMessages
 	)
 
-	if maxMsgs == 0 || msgCount <= maxMsgs {
-		msgs = m.activeChat.history.ChatLog
-	} else {
-		msgs = m.activeChat.history.ChatLog[msgCount-maxMsgs:]
+	if maxMsgs != 0 && msgCount > maxMsgs {
+		msgs = trimToolResults(msgs[msgCount-maxMsgs:])
+	}
+
+	model := m.conf.Model
+	if hasImages(msgs) {
+		model = m.conf.VisionModel
 	}
 
 	req := openai.ChatCompletionRequest{
-		Model:     openai.GPT3Dot5Turbo,
-		Messages:  msgs,
-		MaxTokens: env.GetInt(env.MaxRequestTokens),
+		Model:     model,
+		Messages:  requestMessages(msgs),
+		MaxTokens: m.conf.MaxRequestTokens,
+	}
+	applySampling(&req, m.conf.Sampling.Merge(history.Settings))
+
+	// vision models do not support tool calls
+	if m.tools != nil && !hasImages(msgs) {
+		req.Tools = m.tools.Definitions()
+	}
+
+	return req
+}
+
+// sendGptRequest sends off a completion request to the chat gpt api
+//
+// If the model responds with tool calls they will be run and their results fed back to the model
+// until it gives a final reply
+//
+// Every round after the first is checked against the budget, a round that would go over it is
+// blocked without asking as the user can't be prompted from here
+//
+// This runs outside of the update loop so must not touch the chat log, intermediate entries are
+// sent to the update loop as chatEntryMsg and the final reply is returned as a chatResultMsg
+func sendGptRequest(m *Model, chatId int, req openai.ChatCompletionRequest) tea.Msg {
+	for i := 0; i < maxToolRounds; i++ {
+		// the first round was checked before the request was started, each round after it sends
+		// the growing prompt again so it has to fit in the budget as well
+		if i > 0 {
+			if err := checkBudget(m, req); err != nil {
+				return chatResultMsg{chatId: chatId, blocked: err}
+			}
+		}
+
+		resp, err := createCompletion(m, chatId, req)
+		if err != nil {
+			return chatResultMsg{chatId: chatId, err: err}
+		}
+
+		reply := resp.Choices[0].Message
+		usage := responseUsage(m, resp)
+
+		if len(reply.ToolCalls) == 0 {
+			return chatResultMsg{chatId: chatId, message: reply.Content, usage: usage}
+		}
+
+		m.program.Send(chatEntryMsg{chatId, store.Message{
+			Role:      openai.ChatMessageRoleAssistant,
+			Content:   reply.Content,
+			ToolCalls: reply.ToolCalls,
+			Usage:     usage,
+		}})
+		req.Messages = append(req.Messages, reply)
+
+		for _, call := range reply.ToolCalls {
+			result := runToolCall(m, chatId, call)
+
+			m.program.Send(chatEntryMsg{chatId, result})
+			req.Messages = append(req.Messages, requestMessages(store.ChatLog{result})...)
+		}
+	}
+
+	return chatResultMsg{chatId: chatId, err: fmt.Errorf("gave up after %d rounds of tool calls", maxToolRounds)}
+}
+
+// createCompletion sends the request to the openai API
+// rate limits and transient errors are retried with backoff up to conf.MaxRetries times
+func createCompletion(m *Model, chatId int, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	for attempt := 1; ; attempt++ {
+		ctx, hint := retry.WithHint(m.ctx)
+
+		resp, err := m.client.CreateChatCompletion(ctx, req)
+		if err == nil && len(resp.Choices) == 0 {
+			err = errors.New("the API returned an empty response")
+		}
+		if err == nil {
+			return resp, nil
+		}
+
+		reqErr := retry.Classify(err)
+		reqErr.RetryAfter = hint.RetryAfter()
+		if !reqErr.Kind.Retryable() || attempt > m.conf.MaxRetries {
+			return resp, reqErr
+		}
+
+		delay := retry.Backoff(attempt, reqErr.RetryAfter)
+		m.program.Send(retryMsg{
+			chatId:  chatId,
+			kind:    reqErr.Kind,
+			attempt: attempt,
+			max:     m.conf.MaxRetries,
+			at:      time.Now().Add(delay),
+		})
+
+		if !retry.Sleep(m.ctx, delay) {
+			return resp, retry.Classify(m.ctx.Err())
+		}
 	}
-	resp, err := m.client.CreateChatCompletion(m.ctx, req)
+}
+
+// responseUsage extracts the token usage from the completion response and prices it
+func responseUsage(m *Model, resp openai.ChatCompletionResponse) *store.Usage {
+	return &store.Usage{
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		Cost:             m.conf.Pricing.Cost(resp.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens),
+	}
+}
+
+// requestMessages converts the stored chat log into the message format expected by the openai API
+func requestMessages(log store.ChatLog) []openai.ChatCompletionMessage {
+	msgs := make([]openai.ChatCompletionMessage, 0, len(log))
+
+	for _, msg := range log {
+		if len(msg.Images) == 0 {
+			msgs = append(msgs, openai.ChatCompletionMessage{
+				Role:       msg.Role,
+				Content:    messageContent(msg),
+				ToolCalls:  msg.ToolCalls,
+				ToolCallID: msg.ToolCallID,
+			})
+			continue
+		}
+
+		// images can only be sent to vision models as part of multi part content
+		parts := make([]openai.ChatMessagePart, 0, len(msg.Images)+1)
+		if content := messageContent(msg); content != "" {
+			parts = append(parts, openai.ChatMessagePart{
+				Type: openai.ChatMessagePartTypeText,
+				Text: content,
+			})
+		}
+
+		for _, img := range msg.Images {
+			parts = append(parts, imagePart(img))
+		}
+
+		msgs = append(msgs, openai.ChatCompletionMessage{
+			Role:         msg.Role,
+			MultiContent: parts,
+		})
+	}
+
+	return msgs
+}
+
+// applySampling sets the sampling parameters on the request
+func applySampling(req *openai.ChatCompletionRequest, s store.Sampling) {
+	if s.Temperature != nil {
+		req.Temperature = *s.Temperature
+		// temperature is omitted from the request when it is 0 which would leave the API to use its
+		// default of 1, sending the smallest possible value instead gives the intended behaviour
+		if req.Temperature == 0 {
+			req.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+	if s.TopP != nil {
+		req.TopP = *s.TopP
+		// top_p is omitted at 0 in the same way as temperature
+		if req.TopP == 0 {
+			req.TopP = math.SmallestNonzeroFloat32
+		}
+	}
+	if s.PresencePenalty != nil {
+		req.PresencePenalty = *s.PresencePenalty
+	}
+	if s.FrequencyPenalty != nil {
+		req.FrequencyPenalty = *s.FrequencyPenalty
+	}
+
+	req.Stop = s.Stop
+	req.Seed = s.Seed
+}
+
+// contextMessages filters the chat log down to the messages that should be sent to the model
+// notices are only for the user so are left out
+func contextMessages(log store.ChatLog) store.ChatLog {
+	msgs := make(store.ChatLog, 0, len(log))
+
+	for _, msg := range log {
+		if msg.Status == "" {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	return msgs
+}
 
-	if err != nil {
-		fmt.Print(err)
-		m.program.Send(chatResultMsg{err: err})
-		return
+// trimToolResults drops any tool results from the start of a truncated chat log
+// the openai API rejects tool results that are not preceded by the message that called them
+func trimToolResults(log store.ChatLog) store.ChatLog {
+	for len(log) > 0 && log[0].Role == openai.ChatMessageRoleTool {
+		log = log[1:]
 	}
 
-	m.program.Send(chatResultMsg{message: resp.Choices[0].Message.Content})
+	return log
 }
@@ -0,0 +1,77 @@
+package gpt
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/sashabaranov/go-openai"
+)
+
+// failTurn marks the last prompt and any entries added while answering it as failed so they are
+// left out of the context of future requests
+func failTurn(history *store.ChatHistory) {
+	log := history.ChatLog
+
+	for i := len(log) - 1; i >= 0; i-- {
+		if log[i].Status != "" {
+			continue
+		}
+
+		log[i].Status = store.StatusFailed
+		if log[i].Role == openai.ChatMessageRoleUser {
+			break
+		}
+	}
+}
+
+// lastFailedPrompt finds the index of the most recent failed prompt in the chat log, -1 if there
+// is none
+func lastFailedPrompt(log store.ChatLog) int {
+	for i := len(log) - 1; i >= 0; i-- {
+		if log[i].Status == store.StatusFailed && log[i].Role == openai.ChatMessageRoleUser {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// canResend reports if the active chat has a failed prompt that can be sent again
+func (m *Model) canResend() bool {
+	return !m.busy(m.activeChat.history.Id) && lastFailedPrompt(m.activeChat.history.ChatLog) >= 0
+}
+
+// resendFailed sends the most recent failed prompt again
+//
+// If nothing has been said since the prompt failed then the failed turn is replaced, otherwise
+// the prompt is copied to the end of the chat so the history stays in order
+func (m *Model) resendFailed() tea.Cmd {
+	if !m.canResend() {
+		return nil
+	}
+
+	history := m.activeChat.history
+	log := history.ChatLog
+	idx := lastFailedPrompt(log)
+
+	prompt := log[idx]
+	prompt.Status = ""
+
+	if isFailedTail(log[idx+1:]) {
+		log = log[:idx]
+	}
+
+	history.ChatLog = log
+
+	return m.sendMessage(history, prompt)
+}
+
+// isFailedTail reports if every entry in the log is part of a failed turn or a notice about it
+func isFailedTail(log store.ChatLog) bool {
+	for _, msg := range log {
+		if msg.Status == "" {
+			return false
+		}
+	}
+
+	return true
+}
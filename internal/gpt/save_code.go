@@ -0,0 +1,355 @@
+package gpt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/indeedhat/term-gpt/internal/diff"
+	"github.com/indeedhat/term-gpt/internal/theme"
+)
+
+const (
+	saveStagePick = iota
+	saveStagePath
+	saveStagePreview
+)
+
+const (
+	// modalChrome is the number of lines the code block modals use around their list or preview
+	modalChrome = 6
+	// maxSummaryLen is the max number of characters of code shown for each block in the picker
+	maxSummaryLen = 60
+)
+
+// errFileChanged is returned when the file being saved to changes while the preview is shown
+var errFileChanged = errors.New("the file has changed since the preview was shown, check the preview again")
+
+// saveCodeForm is the modal used to pick a code block from the chat and write it to a file
+//
+// It has three stages, picking the block, entering the path to save it to and previewing the
+// changes that will be made to the file
+type saveCodeForm struct {
+	blocks []codeBlock
+	cursor int
+	stage  int
+	path   textinput.Model
+	// target is the path the block will be written to, resolved from the path input
+	target string
+	// base is the content of the target the preview was built from, exists is unset if there was
+	// no file
+	base    string
+	exists  bool
+	preview string
+	// scroll is the number of preview lines scrolled past
+	scroll int
+	err    string
+}
+
+// newSaveCodeForm sets up the form with the most recent code block selected
+func newSaveCodeForm(blocks []codeBlock) saveCodeForm {
+	path := textinput.New()
+	path.Prompt = "> "
+	path.Placeholder = "path/to/file"
+
+	return saveCodeForm{blocks: blocks, cursor: len(blocks) - 1, path: path}
+}
+
+// Update passes messages to the path input while it is showing
+func (f *saveCodeForm) Update(msg tea.Msg) tea.Cmd {
+	if f.stage != saveStagePath {
+		return nil
+	}
+
+	var cmd tea.Cmd
+	f.path, cmd = f.path.Update(msg)
+
+	return cmd
+}
+
+// View renders the current stage of the form, height is the number of lines available
+func (f saveCodeForm) View(styles theme.Styles, height int) string {
+	var (
+		buf  strings.Builder
+		rows = max(1, height-modalChrome)
+	)
+
+	switch f.stage {
+	case saveStagePick:
+		buf.WriteString("Save Code Block\n\n")
+
+		start := max(0, min(f.cursor-rows/2, len(f.blocks)-rows))
+		for i := start; i < len(f.blocks) && i < start+rows; i++ {
+			cursor := "  "
+			if i == f.cursor {
+				cursor = styles.Accent.Render("> ")
+			}
+
+			buf.WriteString(fmt.Sprintf("%s%2d. %-12s %s\n", cursor, i+1, blockLanguage(f.blocks[i]), blockSummary(f.blocks[i])))
+		}
+
+		buf.WriteString("\nenter: choose • esc: cancel • up/down: move\n")
+	case saveStagePath:
+		buf.WriteString(fmt.Sprintf("Save code block %d to\n\n", f.cursor+1))
+		buf.WriteString(f.path.View() + "\n")
+		buf.WriteString("\nenter: preview • esc: back\n")
+	case saveStagePreview:
+		buf.WriteString(fmt.Sprintf("Save code block %d to %s\n\n", f.cursor+1, f.target))
+
+		buf.WriteString(pageLines(f.preview, f.scroll, rows) + "\n")
+
+		buf.WriteString("\nenter/y: write file • esc: back • up/down: scroll\n")
+	}
+
+	if f.err != "" {
+		buf.WriteString("\n" + styles.Error.Render(f.err) + "\n")
+	}
+
+	return buf.String()
+}
+
+// move changes the highlighted block in the picker
+func (f *saveCodeForm) move(delta int) {
+	f.cursor = max(0, min(len(f.blocks)-1, f.cursor+delta))
+}
+
+// choose moves on to asking for the path, filled in from the file name in the fence if there is one
+func (f *saveCodeForm) choose() tea.Cmd {
+	f.stage = saveStagePath
+	f.err = ""
+	f.path.SetValue(f.blocks[f.cursor].Filename())
+	f.path.CursorEnd()
+
+	return f.path.Focus()
+}
+
+// back returns to the previous stage of the form
+func (f *saveCodeForm) back() {
+	f.err = ""
+	f.stage--
+
+	if f.stage == saveStagePick {
+		f.path.Blur()
+	} else {
+		f.path.Focus()
+	}
+}
+
+// showPreview diffs the code block against the current content of the file
+func (f *saveCodeForm) showPreview(styles theme.Styles) {
+	f.err = ""
+
+	target, err := expandPath(strings.TrimSpace(f.path.Value()))
+	if err != nil {
+		f.err = err.Error()
+		return
+	}
+
+	f.target = target
+	if err := f.diffTarget(styles); err != nil {
+		f.err = err.Error()
+		return
+	}
+
+	f.path.Blur()
+	f.stage = saveStagePreview
+}
+
+// diffTarget reads the target file and builds the preview of the changes from it, the content is
+// kept so write can tell if the file changes after the preview has been shown
+func (f *saveCodeForm) diffTarget(styles theme.Styles) error {
+	current, exists, err := readTarget(f.target)
+	if err != nil {
+		return err
+	}
+
+	oldName := f.target
+	if !exists {
+		oldName = "/dev/null"
+	}
+
+	f.base, f.exists = current, exists
+	f.scroll = 0
+	f.preview = renderDiff(styles, diff.Unified(oldName, f.target, current, f.content(), 3))
+	if f.preview == "" {
+		f.preview = "The file already contains this code block"
+	}
+
+	return nil
+}
+
+// readTarget reads the content of the file the code block is saved to, a missing file is empty
+func readTarget(target string) (content string, exists bool, err error) {
+	data, err := os.ReadFile(target)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return "", false, nil
+	case err != nil:
+		return "", false, err
+	default:
+		return string(data), true, nil
+	}
+}
+
+// scrollPreview scrolls the diff preview by delta lines
+func (f *saveCodeForm) scrollPreview(delta int) {
+	f.scroll = scrollLines(f.preview, f.scroll, delta)
+}
+
+// content is the text that will be written to the file
+func (f saveCodeForm) content() string {
+	code := f.blocks[f.cursor].Code
+	if code == "" || strings.HasSuffix(code, "\n") {
+		return code
+	}
+
+	return code + "\n"
+}
+
+// write saves the code block to the target file, creating any missing directories
+// the permissions of an existing file are kept
+//
+// If the file has changed since the preview was built nothing is written, the preview is built
+// again from the new content and errFileChanged is returned
+func (f *saveCodeForm) write(styles theme.Styles) error {
+	current, exists, err := readTarget(f.target)
+	if err != nil {
+		return err
+	}
+
+	if current != f.base || exists != f.exists {
+		if err := f.diffTarget(styles); err != nil {
+			return err
+		}
+
+		return errFileChanged
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(f.target); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.target), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(f.target, []byte(f.content()), mode)
+}
+
+// openSaveCode opens the save code modal listing the code blocks in the replies of the active chat
+// in selection mode only the blocks from the selected message are listed
+func (m *Model) openSaveCode() {
+	blocks := m.activeChat.codeBlocks()
+	if msg, ok := m.selectedMessage(); ok && m.focus == elemSelect {
+		blocks = parseCodeBlocks(msg.Content)
+	}
+
+	if len(blocks) == 0 {
+		m.notice = "There are no code blocks to save"
+		return
+	}
+
+	m.saveCode = newSaveCodeForm(blocks)
+	m.focusElement(elemSaveCode)
+}
+
+// handleSaveCodeKey handles the key presses for the save code modal
+// handled is false if the key press should be passed on to the path input
+func (m *Model) handleSaveCodeKey(msg tea.KeyMsg) (cmd tea.Cmd, handled bool) {
+	f := &m.saveCode
+
+	if msg.String() == "esc" {
+		if f.stage == saveStagePick {
+			m.focusElement(elemTextArea)
+		} else {
+			f.back()
+		}
+
+		return nil, true
+	}
+
+	switch f.stage {
+	case saveStagePick:
+		switch msg.String() {
+		case "up", "k":
+			f.move(-1)
+		case "down", "j":
+			f.move(1)
+		case "enter":
+			return f.choose(), true
+		}
+	case saveStagePath:
+		if msg.String() != "enter" {
+			return nil, false
+		}
+
+		f.showPreview(m.styles)
+	case saveStagePreview:
+		switch msg.String() {
+		case "up", "k":
+			f.scrollPreview(-1)
+		case "down", "j":
+			f.scrollPreview(1)
+		case "enter", "y":
+			if err := f.write(m.styles); err != nil {
+				f.err = err.Error()
+				break
+			}
+
+			m.notice = fmt.Sprintf("Saved code block to %s", f.target)
+			m.focusElement(elemTextArea)
+		}
+	}
+
+	return nil, true
+}
+
+// blockLanguage is the language shown for the block in the picker
+func blockLanguage(block codeBlock) string {
+	if block.Language == "" || block.Language == block.Filename() {
+		return "text"
+	}
+
+	return block.Language
+}
+
+// blockSummary describes the block in the picker using the file name from the fence or the first
+// line of code
+func blockSummary(block codeBlock) string {
+	if name := block.Filename(); name != "" {
+		return name
+	}
+
+	line := []rune(firstLine(block.Code))
+	if len(line) == 0 {
+		return "(empty)"
+	}
+	if len(line) > maxSummaryLen {
+		return string(line[:maxSummaryLen]) + "…"
+	}
+
+	return string(line)
+}
+
+// expandPath expands a leading ~ to the users home directory
+func expandPath(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("enter the path to save the code block to")
+	}
+
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, path[1:]), nil
+}
@@ -0,0 +1,99 @@
+package gpt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/indeedhat/term-gpt/internal/theme"
+)
+
+func TestSaveCodeWrite(t *testing.T) {
+	styles := theme.Default().Styles()
+
+	cases := []struct {
+		name string
+		// initial is the content of the file when the preview is shown, nil for no file
+		initial *string
+		// change is applied to the file between the preview and the write
+		change  func(path string) error
+		changed bool
+	}{
+		{name: "new file", changed: false},
+		{name: "unchanged file", initial: ptr("old\n"), changed: false},
+		{
+			name:    "file edited",
+			initial: ptr("old\n"),
+			change:  func(path string) error { return os.WriteFile(path, []byte("edited\n"), 0644) },
+			changed: true,
+		},
+		{
+			name:    "file created",
+			change:  func(path string) error { return os.WriteFile(path, []byte("created\n"), 0644) },
+			changed: true,
+		},
+		{
+			name:    "file removed",
+			initial: ptr("old\n"),
+			change:  os.Remove,
+			changed: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "main.go")
+			if c.initial != nil {
+				if err := os.WriteFile(path, []byte(*c.initial), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			f := newSaveCodeForm([]codeBlock{{Language: "go", Code: "package main"}})
+			f.path.SetValue(path)
+			f.showPreview(styles)
+			if f.stage != saveStagePreview {
+				t.Fatalf("showPreview() error = %s", f.err)
+			}
+
+			if c.change != nil {
+				if err := c.change(path); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before, _ := os.ReadFile(path)
+
+			err := f.write(styles)
+			if !c.changed {
+				if err != nil {
+					t.Fatalf("write() error = %v", err)
+				}
+				if data, _ := os.ReadFile(path); string(data) != "package main\n" {
+					t.Fatalf("file = %q after write()", data)
+				}
+				return
+			}
+
+			if !errors.Is(err, errFileChanged) {
+				t.Fatalf("write() error = %v, want %v", err, errFileChanged)
+			}
+			if data, _ := os.ReadFile(path); string(data) != string(before) {
+				t.Fatalf("file = %q after a refused write(), want %q", data, before)
+			}
+			if _, err := os.Stat(path); f.base != string(before) || f.exists != (err == nil) {
+				t.Errorf("preview was not rebuilt from the changed file:\n%s", f.preview)
+			}
+
+			// the second write goes ahead now the preview matches the file
+			if err := f.write(styles); err != nil {
+				t.Fatalf("second write() error = %v", err)
+			}
+		})
+	}
+}
+
+// ptr returns a pointer to s
+func ptr(s string) *string {
+	return &s
+}
@@ -0,0 +1,151 @@
+package gpt
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/indeedhat/term-gpt/internal/clipboard"
+	"github.com/indeedhat/term-gpt/internal/store"
+)
+
+// enterSelectMode highlights the last message in the active chat so it can be copied
+func (m *Model) enterSelectMode() {
+	if len(m.activeChat.history.ChatLog) == 0 {
+		m.notice = "There are no messages to select"
+		return
+	}
+
+	m.focusElement(elemSelect)
+	m.activeChat.selected = len(m.activeChat.history.ChatLog) - 1
+	m.renderChat()
+}
+
+// handleSelectKey handles the key presses for selection mode
+// handled is false if the key press should be handled by the global key bindings instead
+func (m *Model) handleSelectKey(msg tea.KeyMsg) (cmd tea.Cmd, handled bool) {
+	switch {
+	case msg.String() == "esc", key.Matches(msg, m.keys.SelectMode):
+		m.focusElement(elemTextArea)
+	case key.Matches(msg, m.keys.PrevMessage):
+		m.moveSelection(-1)
+	case key.Matches(msg, m.keys.NextMessage):
+		m.moveSelection(1)
+	case key.Matches(msg, m.keys.Copy):
+		return m.copySelected(), true
+	case key.Matches(msg, m.keys.CopyCode):
+		return m.copyCodeBlock(slices.Index(m.keys.CopyCode.Keys(), msg.String()) + 1), true
+	default:
+		return nil, false
+	}
+
+	return nil, true
+}
+
+// moveSelection moves the selection up or down by delta messages, stopping at either end of the chat
+func (m *Model) moveSelection(delta int) {
+	last := len(m.activeChat.history.ChatLog) - 1
+	m.activeChat.selected = max(0, min(last, m.activeChat.selected+delta))
+	m.renderChat()
+}
+
+// selectedMessage returns the message highlighted in selection mode
+func (m *Model) selectedMessage() (store.Message, bool) {
+	i := m.activeChat.selected
+	if i < 0 || i >= len(m.activeChat.history.ChatLog) {
+		return store.Message{}, false
+	}
+
+	return m.activeChat.history.ChatLog[i], true
+}
+
+// copySelected copies the raw markdown of the selected message to the clipboard
+func (m *Model) copySelected() tea.Cmd {
+	msg, ok := m.selectedMessage()
+	if !ok {
+		return nil
+	}
+
+	if msg.Content == "" {
+		m.notice = "The selected message has no text to copy"
+		return nil
+	}
+
+	return copyToClipboard(msg.Content, "message")
+}
+
+// copyCodeBlock copies the nth code block (as numbered in the chat) of the selected message to the
+// clipboard
+func (m *Model) copyCodeBlock(n int) tea.Cmd {
+	msg, ok := m.selectedMessage()
+	if !ok {
+		return nil
+	}
+
+	blocks := parseCodeBlocks(msg.Content)
+	if n < 1 || n > len(blocks) {
+		m.notice = fmt.Sprintf("The selected message has no code block %d", n)
+		return nil
+	}
+
+	return copyToClipboard(blocks[n-1].Code, fmt.Sprintf("code block %d", n))
+}
+
+// copyToClipboard returns the command that sends the text to the terminal clipboard, the outcome
+// is delivered back to the update loop as a clipboardMsg
+//
+// The OSC 52 sequence has to be written to the terminal without the renderer drawing a frame at
+// the same time. tea.Println is dropped while the alt screen is active so the write is run with
+// tea.Exec instead, which stops the renderer until it is done
+func copyToClipboard(text, what string) tea.Cmd {
+	return tea.Exec(&clipboardWriter{text: text}, func(err error) tea.Msg {
+		return clipboardMsg{what: what, err: err}
+	})
+}
+
+// clipboardWriter is a tea.ExecCommand that writes the OSC 52 sequence to the terminal
+type clipboardWriter struct {
+	text   string
+	stdout io.Writer
+}
+
+// Run implements tea.ExecCommand.
+func (c *clipboardWriter) Run() error {
+	return clipboard.Copy(c.stdout, c.text)
+}
+
+// SetStdin implements tea.ExecCommand.
+func (c *clipboardWriter) SetStdin(io.Reader) {}
+
+// SetStdout implements tea.ExecCommand.
+func (c *clipboardWriter) SetStdout(w io.Writer) {
+	c.stdout = w
+}
+
+// SetStderr implements tea.ExecCommand.
+func (c *clipboardWriter) SetStderr(io.Writer) {}
+
+var _ tea.ExecCommand = (*clipboardWriter)(nil)
+
+// handleClipboardMsg lets the user know how copying to the clipboard went
+func (m *Model) handleClipboardMsg(msg clipboardMsg) tea.Cmd {
+	if msg.err != nil {
+		m.notice = fmt.Sprintf("Error: %s", msg.err)
+	} else {
+		m.notice = fmt.Sprintf("Copied %s to the clipboard", msg.what)
+	}
+
+	return m.restoreMouse()
+}
+
+// restoreMouse turns mouse reporting back on after tea.Exec has released the terminal, bubbletea
+// turns it off while the terminal is released but does not turn it back on
+func (m *Model) restoreMouse() tea.Cmd {
+	if !m.conf.Mouse {
+		return nil
+	}
+
+	return tea.EnableMouseCellMotion
+}
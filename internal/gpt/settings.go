@@ -0,0 +1,254 @@
+package gpt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/indeedhat/term-gpt/internal/theme"
+)
+
+const (
+	fieldTemperature = iota
+	fieldTopP
+	fieldPresencePenalty
+	fieldFrequencyPenalty
+	fieldStop
+	fieldSeed
+)
+
+var settingsLabels = []string{
+	fieldTemperature:      "Temperature",
+	fieldTopP:             "Top P",
+	fieldPresencePenalty:  "Presence penalty",
+	fieldFrequencyPenalty: "Frequency penalty",
+	fieldStop:             "Stop sequences",
+	fieldSeed:             "Seed",
+}
+
+// settingsForm is the modal used to edit the sampling parameters of the active chat
+//
+// Fields left empty fall back to the defaults from the config file
+type settingsForm struct {
+	inputs []textinput.Model
+	focus  int
+	err    string
+}
+
+// newSettingsForm sets up the settings form with the current chat settings filled in and the
+// config defaults shown as placeholders
+func newSettingsForm(defaults, current store.Sampling) settingsForm {
+	defaultVals := samplingValues(defaults)
+	currentVals := samplingValues(current)
+
+	inputs := make([]textinput.Model, len(settingsLabels))
+	for i := range inputs {
+		input := textinput.New()
+		input.Prompt = ""
+		input.Placeholder = defaultVals[i]
+		if input.Placeholder == "" {
+			input.Placeholder = "API default"
+		}
+		input.SetValue(currentVals[i])
+		inputs[i] = input
+	}
+
+	inputs[fieldStop].Placeholder += " (comma separated)"
+	inputs[0].Focus()
+
+	return settingsForm{inputs: inputs}
+}
+
+// Update moves the focus between fields and passes all other messages to the focused input
+func (f *settingsForm) Update(msg tea.Msg) tea.Cmd {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "tab", "down":
+			f.focusField((f.focus + 1) % len(f.inputs))
+			return nil
+		case "shift+tab", "up":
+			f.focusField((f.focus + len(f.inputs) - 1) % len(f.inputs))
+			return nil
+		}
+	}
+
+	var cmd tea.Cmd
+	f.inputs[f.focus], cmd = f.inputs[f.focus].Update(msg)
+
+	return cmd
+}
+
+// focusField moves the cursor to the field at idx
+func (f *settingsForm) focusField(idx int) {
+	f.inputs[f.focus].Blur()
+	f.focus = idx
+	f.inputs[f.focus].Focus()
+}
+
+// View renders the form
+func (f settingsForm) View(styles theme.Styles) string {
+	var buf strings.Builder
+	buf.WriteString("Chat Settings\n\n")
+
+	for i, input := range f.inputs {
+		cursor := "  "
+		if i == f.focus {
+			cursor = styles.Accent.Render("> ")
+		}
+
+		buf.WriteString(fmt.Sprintf("%s%-18s %s\n", cursor, settingsLabels[i], input.View()))
+	}
+
+	buf.WriteString("\nstop sequences are separated by commas, write \\, for a comma and \\n for a new line\n")
+	buf.WriteString("\nenter: save • esc: cancel • tab: next field\n")
+	if f.err != "" {
+		buf.WriteString("\n" + styles.Error.Render(f.err) + "\n")
+	}
+
+	return buf.String()
+}
+
+// Sampling parses the form values into the sampling parameters for the chat
+func (f settingsForm) Sampling() (store.Sampling, error) {
+	var (
+		s    store.Sampling
+		errs []error
+	)
+
+	// a slice rather than a map so the errors are always listed in the order of the fields
+	floats := []struct {
+		idx   int
+		field **float32
+	}{
+		{fieldTemperature, &s.Temperature},
+		{fieldTopP, &s.TopP},
+		{fieldPresencePenalty, &s.PresencePenalty},
+		{fieldFrequencyPenalty, &s.FrequencyPenalty},
+	}
+	for _, float := range floats {
+		val := strings.TrimSpace(f.inputs[float.idx].Value())
+		if val == "" {
+			continue
+		}
+
+		num, err := strconv.ParseFloat(val, 32)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s must be a number", strings.ToLower(settingsLabels[float.idx])))
+			continue
+		}
+
+		num32 := float32(num)
+		*float.field = &num32
+	}
+
+	s.Stop = splitStops(f.inputs[fieldStop].Value())
+
+	if val := strings.TrimSpace(f.inputs[fieldSeed].Value()); val != "" {
+		seed, err := strconv.Atoi(val)
+		if err != nil {
+			errs = append(errs, errors.New("seed must be a whole number"))
+		} else {
+			s.Seed = &seed
+		}
+	}
+
+	if len(errs) > 0 {
+		return s, errors.Join(errs...)
+	}
+
+	return s, s.Validate()
+}
+
+// samplingValues formats the sampling parameters as strings in the order of the form fields
+func samplingValues(s store.Sampling) []string {
+	vals := make([]string, len(settingsLabels))
+
+	floats := map[int]*float32{
+		fieldTemperature:      s.Temperature,
+		fieldTopP:             s.TopP,
+		fieldPresencePenalty:  s.PresencePenalty,
+		fieldFrequencyPenalty: s.FrequencyPenalty,
+	}
+	for idx, val := range floats {
+		if val != nil {
+			vals[idx] = strconv.FormatFloat(float64(*val), 'f', -1, 32)
+		}
+	}
+
+	vals[fieldStop] = joinStops(s.Stop)
+
+	if s.Seed != nil {
+		vals[fieldSeed] = strconv.Itoa(*s.Seed)
+	}
+
+	return vals
+}
+
+// stopEscapes are the characters written with a backslash in the stop sequences field, a comma
+// would otherwise split the sequence in two
+var stopEscapes = map[rune]rune{
+	'n':  '\n',
+	',':  ',',
+	'\\': '\\',
+}
+
+// splitStops reads the comma separated stop sequences from the settings form
+// \, is a literal comma, \n a new line and \\ a backslash, any other backslash is kept as is
+func splitStops(val string) []string {
+	var (
+		stops   []string
+		buf     strings.Builder
+		escaped bool
+	)
+
+	add := func() {
+		// only spaces are trimmed so escaped new lines are kept
+		if stop := strings.Trim(buf.String(), " \t"); stop != "" {
+			stops = append(stops, stop)
+		}
+		buf.Reset()
+	}
+
+	for _, r := range val {
+		switch {
+		case escaped:
+			if unescaped, ok := stopEscapes[r]; ok {
+				buf.WriteRune(unescaped)
+			} else {
+				buf.WriteRune('\\')
+				buf.WriteRune(r)
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			add()
+		default:
+			buf.WriteRune(r)
+		}
+	}
+
+	if escaped {
+		buf.WriteRune('\\')
+	}
+	add()
+
+	return stops
+}
+
+// joinStops formats the stop sequences for the settings form, escaping them so splitStops reads
+// them back the same
+func joinStops(stops []string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, ",", `\,`, "\n", `\n`)
+
+	escaped := make([]string, 0, len(stops))
+	for _, stop := range stops {
+		escaped = append(escaped, escaper.Replace(stop))
+	}
+
+	return strings.Join(escaped, ", ")
+}
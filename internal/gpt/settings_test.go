@@ -0,0 +1,56 @@
+package gpt
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/indeedhat/term-gpt/internal/store"
+)
+
+func TestSplitStops(t *testing.T) {
+	cases := []struct {
+		name string
+		val  string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"single", "END", []string{"END"}},
+		{"comma separated", "END, STOP ,DONE", []string{"END", "STOP", "DONE"}},
+		{"empty entries", ", END,, ", []string{"END"}},
+		{"escaped comma", `a\,b, c`, []string{"a,b", "c"}},
+		{"new line", `\n\n, ###`, []string{"\n\n", "###"}},
+		{"backslash", `a\\, b\\n`, []string{`a\`, `b\n`}},
+		{"unknown escape", `\t`, []string{`\t`}},
+		{"trailing backslash", `end\`, []string{`end\`}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := splitStops(c.val); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("splitStops(%q) = %q, want %q", c.val, got, c.want)
+			}
+
+			// the form shows the stops escaped so they read back the same
+			if got := splitStops(joinStops(c.want)); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("splitStops(joinStops(%q)) = %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestSettingsErrorOrder(t *testing.T) {
+	f := newSettingsForm(store.Sampling{}, store.Sampling{})
+	for _, idx := range []int{fieldTemperature, fieldTopP, fieldPresencePenalty, fieldFrequencyPenalty, fieldSeed} {
+		f.inputs[idx].SetValue("x")
+	}
+
+	want := "temperature must be a number\ntop p must be a number\npresence penalty must be a number\n" +
+		"frequency penalty must be a number\nseed must be a whole number"
+
+	// a few runs so an order that depends on map iteration shows up
+	for i := 0; i < 10; i++ {
+		if _, err := f.Sampling(); err == nil || err.Error() != want {
+			t.Fatalf("Sampling() error =\n%v\nwant\n%s", err, want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package gpt
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/indeedhat/term-gpt/internal/theme"
+)
+
+const (
+	// contextWarning is the fraction of the context window above which the estimate is highlighted
+	contextWarning = 0.8
+	// minStatusTitle is the fewest cells the chat title is squeezed into before details are dropped
+	minStatusTitle = 12
+)
+
+// requestState is the state of the request for the active chat shown in the status bar
+type requestState int
+
+const (
+	stateIdle requestState = iota
+	// stateWaiting is set while waiting on the reply, the API is not streamed so this covers the
+	// whole request
+	stateWaiting
+	stateRetrying
+)
+
+// String implements fmt.Stringer.
+func (s requestState) String() string {
+	switch s {
+	case stateWaiting:
+		return "waiting"
+	case stateRetrying:
+		return "retrying"
+	default:
+		return "idle"
+	}
+}
+
+// focusLabels names each focused element in the status bar
+var focusLabels = map[focusedElement]string{
+	elemTextArea:    "prompt",
+	elemChatHistory: "history",
+	elemFilePicker:  "file picker",
+	elemServers:     "servers",
+	elemSettings:    "settings",
+	elemHelp:        "help",
+	elemSelect:      "select",
+	elemSaveCode:    "save code",
+	elemApplyDiff:   "apply diff",
+	elemTheme:       "theme",
+}
+
+// statusChatMsg updates the status bar with the active chat
+type statusChatMsg struct {
+	chatId int
+	title  string
+	usage  store.Usage
+}
+
+// statusContextMsg updates the status bar with the estimated size of the next request for a chat
+type statusContextMsg struct {
+	chatId int
+	model  string
+	tokens int
+	// limit is the context window of the model, 0 if it is not known
+	limit int
+}
+
+// statusRequestMsg updates the status bar with the state of the active chat's request
+type statusRequestMsg struct {
+	chatId int
+	state  requestState
+	retry  *retryMsg
+	queued int
+}
+
+// statusFocusMsg updates the status bar with the focused element
+type statusFocusMsg struct {
+	focus focusedElement
+}
+
+// statusBar is the line at the top of the screen
+//
+// It is only ever changed by the status messages, these are sent by syncStatus whenever the state
+// it shows changes so the bar never has to reach into the rest of the model
+type statusBar struct {
+	chatId int
+	title  string
+	usage  store.Usage
+
+	model  string
+	tokens int
+	limit  int
+
+	state  requestState
+	retry  *retryMsg
+	queued int
+
+	focus focusedElement
+}
+
+// statusKey identifies the state of the chat that the context estimate was made for
+type statusKey struct {
+	chatId int
+	// messages is the number of messages that would be sent, failed and notice messages are left out
+	messages int
+}
+
+// Update applies a status message to the bar, context and request updates for a chat other than
+// the active one are ignored as they arrived after the chat was switched
+func (s *statusBar) Update(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case statusChatMsg:
+		if msg.chatId != s.chatId {
+			s.model, s.tokens, s.limit = "", 0, 0
+			s.state, s.retry, s.queued = stateIdle, nil, 0
+		}
+		s.chatId, s.title, s.usage = msg.chatId, msg.title, msg.usage
+	case statusContextMsg:
+		if msg.chatId == s.chatId {
+			s.model, s.tokens, s.limit = msg.model, msg.tokens, msg.limit
+		}
+	case statusRequestMsg:
+		if msg.chatId == s.chatId {
+			s.state, s.retry, s.queued = msg.state, msg.retry, msg.queued
+		}
+	case statusFocusMsg:
+		s.focus = msg.focus
+	}
+}
+
+// View renders the bar with the chat details on the left and the request state and focus on the
+// right, when everything does not fit in width the chat title is truncated and the less useful
+// details are dropped
+func (s statusBar) View(styles theme.Styles, width int) string {
+	sep := styles.Status.Render(" • ")
+
+	state := s.state.String()
+	if s.retry != nil {
+		state = s.retry.countdown()
+	}
+	if s.queued > 0 {
+		state += fmt.Sprintf(" (%d queued)", s.queued)
+	}
+	right := s.stateStyle(styles).Render(state) + sep + styles.Status.Render(focusLabels[s.focus]+" ")
+
+	// in order of importance
+	details := []string{s.contextView(styles)}
+	if s.model != "" {
+		details = append(details, styles.Status.Render(s.model))
+	}
+	details = append(details, styles.Status.Render(fmt.Sprintf("$%.4f", s.usage.Cost)))
+
+	var (
+		rest string
+		room int
+	)
+	for n := len(details); n >= 0; n-- {
+		rest = ""
+		if n > 0 {
+			rest = sep + strings.Join(details[:n], sep)
+		}
+
+		// one cell for the leading space and at least one between the two halves
+		room = width - lipgloss.Width(rest) - lipgloss.Width(right) - 2
+		if room >= minStatusTitle {
+			break
+		}
+	}
+
+	title := styles.Status.Render(" " + truncate(s.title, max(1, room)))
+	gap := max(1, width-lipgloss.Width(title)-lipgloss.Width(rest)-lipgloss.Width(right))
+
+	return lipgloss.NewStyle().MaxWidth(width).Render(title + rest + strings.Repeat(" ", gap) + right)
+}
+
+// contextView formats the context estimate, highlighting it as it gets close to the limit
+func (s statusBar) contextView(styles theme.Styles) string {
+	if s.limit == 0 {
+		return styles.Status.Render(fmt.Sprintf("ctx ~%s", formatTokens(s.tokens)))
+	}
+
+	view := fmt.Sprintf("ctx ~%s/%s", formatTokens(s.tokens), formatTokens(s.limit))
+	switch {
+	case s.tokens > s.limit:
+		return styles.Fail.Render(view)
+	case float64(s.tokens) > float64(s.limit)*contextWarning:
+		return styles.Warn.Render(view)
+	default:
+		return styles.Status.Render(view)
+	}
+}
+
+// stateStyle picks the style for the request state
+func (s statusBar) stateStyle(styles theme.Styles) lipgloss.Style {
+	switch s.state {
+	case stateWaiting:
+		return styles.Accent
+	case stateRetrying:
+		return styles.Warn
+	default:
+		return styles.Status
+	}
+}
+
+// syncStatus sends the status messages for anything shown in the status bar that has changed
+// since it was last synced
+//
+// The context estimate has to build the full request, which reads any attached images from
+// disk, so it is done in a command on a copy of the chat
+func (m *Model) syncStatus() tea.Cmd {
+	var (
+		cmds    []tea.Cmd
+		history = m.activeChat.history
+		sent    = &m.statusSent
+	)
+
+	send := func(msg tea.Msg) {
+		sent.Update(msg)
+		cmds = append(cmds, func() tea.Msg { return msg })
+	}
+
+	if usage := m.activeChat.usage(); history.Id != sent.chatId || history.ChatTitle != sent.title || usage != sent.usage {
+		send(statusChatMsg{chatId: history.Id, title: history.ChatTitle, usage: usage})
+	}
+
+	request := statusRequestMsg{chatId: history.Id, queued: m.queued()}
+	if req := m.pending[history.Id]; req != nil {
+		request.state, request.retry = stateWaiting, req.retry
+		if req.retry != nil {
+			request.state = stateRetrying
+		}
+	}
+	if request.state != sent.state || request.retry != sent.retry || request.queued != sent.queued {
+		send(request)
+	}
+
+	if m.focus != sent.focus {
+		send(statusFocusMsg{focus: m.focus})
+	}
+
+	key := statusKey{chatId: history.Id, messages: len(contextMessages(history.ChatLog))}
+	if key != m.statusKey {
+		m.statusKey = key
+
+		snapshot := *history
+		snapshot.ChatLog = slices.Clone(history.ChatLog)
+		cmds = append(cmds, func() tea.Msg {
+			req := buildRequest(m, &snapshot)
+			limit, _ := m.conf.ContextWindows.Lookup(req.Model)
+
+			return statusContextMsg{
+				chatId: snapshot.Id,
+				model:  req.Model,
+				tokens: estimatePromptTokens(req),
+				limit:  limit,
+			}
+		})
+	}
+
+	// the messages are applied in order so the bar has switched chat before the updates for it arrive
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	return tea.Sequence(cmds...)
+}
+
+// formatTokens shortens large token counts, 1234 becomes 1.2k
+func formatTokens(n int) string {
+	switch {
+	case n >= 10_000:
+		return fmt.Sprintf("%dk", n/1000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	default:
+		return fmt.Sprint(n)
+	}
+}
+
+// truncate shortens the text to width cells, ending it with an ellipsis if it was cut
+func truncate(text string, width int) string {
+	if lipgloss.Width(text) <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+		runes = runes[:len(runes)-1]
+	}
+
+	return string(runes) + "…"
+}
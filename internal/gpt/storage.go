@@ -1,35 +1,292 @@
 package gpt
 
-import "github.com/indeedhat/term-gpt/internal/store"
+import (
+	"fmt"
+	"slices"
 
-// updateChatList replaces the chat history with a fresh up to date version from the database
-func updateChatList(m *Model) {
-	m.chatHistoryList.SetItems(historyList(m.repo.List()))
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/indeedhat/term-gpt/internal/store"
+)
+
+// chatPageSize is the number of chats loaded from the database into the history list at a time
+const chatPageSize = 50
+
+// loadMoreChats adds the next page of chats from the database to the history list
+func loadMoreChats(m *Model) {
+	if m.listDone {
+		return
+	}
+
+	opts := listOptions(m)
+	opts.Offset, opts.Limit = m.listLoaded, chatPageSize
+
+	page := m.repo.List(opts)
+	m.listLoaded += len(page)
+	m.listDone = len(page) < chatPageSize
+
+	mergeChats(m, page)
+}
+
+// listOptions returns the tag/starred filter the history list is limited to
+func listOptions(m *Model) store.ListOptions {
+	return store.ListOptions{Tag: m.listTag, Starred: m.listStarred}
+}
+
+// inListScope reports if the chat matches the tag/starred filter of the history list
+func inListScope(m *Model, meta store.ChatHistoryMeta) bool {
+	return (m.listTag == "" || slices.Contains(meta.Tags, m.listTag)) && (!m.listStarred || meta.Starred)
+}
+
+// setListScope limits the history list to the chats with the tag and/or starred chats, an empty tag
+// and starred == false shows every chat
+func setListScope(m *Model, tag string, starred bool) {
+	m.listTag, m.listStarred = tag, starred
+
+	m.chatHistoryList.Title = historyTitle
+	if tag != "" {
+		m.chatHistoryList.Title += " #" + tag
+	}
+	if starred {
+		m.chatHistoryList.Title += " ★"
+	}
+
+	// only the new chat entry is kept, the rest of the chats are paged in again
+	m.addListCmd(m.chatHistoryList.SetItems(slices.Clone(m.chatHistoryList.Items()[:1])))
 	m.chatHistoryList.Select(0)
+	m.listLoaded, m.listDone, m.listFilter = 0, false, ""
+
+	loadMoreChats(m)
+	selectActiveChat(m)
+}
+
+// pageChats loads more chats once the cursor gets within a page of the end of the history list
+func pageChats(m *Model) {
+	if m.chatHistoryList.FilterState() != list.Unfiltered {
+		return
+	}
+
+	remaining := len(m.chatHistoryList.Items()) - 1 - m.chatHistoryList.Index()
+	if remaining < m.chatHistoryList.Paginator.PerPage {
+		loadMoreChats(m)
+	}
+}
+
+// searchChats pulls the chats matching the history filter in from the database so that chats which
+// have not been paged in yet can still be found
+func searchChats(m *Model) {
+	filter := m.chatHistoryList.FilterValue()
+	if filter == m.listFilter {
+		return
+	}
+
+	m.listFilter = filter
+	if filter == "" || m.listDone {
+		return
+	}
+
+	opts := listOptions(m)
+	opts.Filter, opts.Limit = filter, chatPageSize
+
+	mergeChats(m, m.repo.List(opts))
+}
+
+// mergeChats adds any of the chats that are not already in the history list in list order
+func mergeChats(m *Model, chats []store.ChatHistoryMeta) {
+	items := slices.Clone(m.chatHistoryList.Items())
+	added := false
+
+	for _, meta := range chats {
+		if listIndex(items, meta.Id) >= 0 {
+			continue
+		}
+
+		items = slices.Insert(items, listPosition(items, meta), list.Item(withBadges(m, meta)))
+		added = true
+	}
+
+	if added {
+		m.addListCmd(m.chatHistoryList.SetItems(items))
+		selectActiveChat(m)
+	}
+}
+
+// upsertChat moves the chat to its place in the history list after it has been saved adding it if
+// this is the first time it has been saved
+//
+// A chat that no longer matches the tag/starred filter is taken out of the list, as is one that
+// now belongs after the chats paged in so far. Either way it has left the pages already loaded so
+// the offset of the next page is moved back to make up for it
+func upsertChat(m *Model, meta store.ChatHistoryMeta) {
+	loaded := listIndex(m.chatHistoryList.Items(), meta.Id)
+	if loaded >= 0 {
+		m.chatHistoryList.RemoveItem(loaded)
+	}
+
+	idx := listPosition(m.chatHistoryList.Items(), meta)
+	if !inListScope(m, meta) || (idx == len(m.chatHistoryList.Items()) && !m.listDone) {
+		if loaded >= 0 {
+			m.listLoaded = max(0, m.listLoaded-1)
+		}
+
+		return
+	}
+
+	m.addListCmd(m.chatHistoryList.InsertItem(idx, withBadges(m, meta)))
+	selectActiveChat(m)
+}
+
+// refreshBadges updates the pending/unread badges of the chat in the history list
+func refreshBadges(m *Model, id int) {
+	idx := listIndex(m.chatHistoryList.Items(), id)
+	if idx < 0 {
+		return
+	}
+
+	meta := m.chatHistoryList.Items()[idx].(store.ChatHistoryMeta)
+	m.addListCmd(m.chatHistoryList.SetItem(idx, withBadges(m, meta)))
+}
+
+// withBadges fills in the pending/unread state of the chat from the model
+func withBadges(m *Model, meta store.ChatHistoryMeta) store.ChatHistoryMeta {
+	meta.Pending = m.pending[meta.Id] != nil
+	meta.Unread = m.unread[meta.Id]
+
+	return meta
+}
+
+// selectActiveChat moves the cursor of the history list back to the active chat after the list
+// has changed, it is left alone while the list is filtered
+func selectActiveChat(m *Model) {
+	if m.chatHistoryList.FilterState() != list.Unfiltered {
+		return
+	}
+
+	if idx := listIndex(m.chatHistoryList.Items(), m.activeChat.history.Id); idx >= 0 {
+		m.chatHistoryList.Select(idx)
+	}
+}
+
+// listIndex finds the chat in the history list, -1 is returned if it has not been loaded
+func listIndex(items []list.Item, id int) int {
+	return slices.IndexFunc(items, func(item list.Item) bool {
+		return item.(store.ChatHistoryMeta).Id == id
+	})
+}
+
+// listPosition finds where the chat belongs in the history list, the new chat entry always stays
+// at the top followed by the pinned chats then the rest of the chats with the most recently
+// updated first
+func listPosition(items []list.Item, meta store.ChatHistoryMeta) int {
+	for i := 1; i < len(items); i++ {
+		if listedBefore(meta, items[i].(store.ChatHistoryMeta)) {
+			return i
+		}
+	}
+
+	return len(items)
+}
+
+// listedBefore reports if chat a belongs above chat b in the history list, this matches the order
+// chats are returned in by ChatHistoryRepo.List
+func listedBefore(a, b store.ChatHistoryMeta) bool {
+	switch {
+	case a.Pinned != b.Pinned:
+		return a.Pinned
+	case !a.UpdatedAt.Equal(b.UpdatedAt):
+		return a.UpdatedAt.After(b.UpdatedAt)
+	default:
+		return a.Id > b.Id
+	}
 }
 
 // loadChat loads the full chat by id into the models activeChat struct
 func loadChat(m *Model) {
 	item := m.chatHistoryList.SelectedItem().(store.ChatHistoryMeta)
 	if item.Id == 0 {
-		m.activeChat = newChatLog()
-	} else {
-		m.activeChat.history = m.repo.Find(item.Id)
+		m.activeChat = newChatLog(m.theme)
+	} else if history := m.repo.Find(item.Id); history != nil {
+		m.activeChat.history = history
+		// none of the cached messages belong to the new chat
+		m.activeChat.cache.clear()
 	}
+	m.activeChat.selected = -1
+	// start the new chat from the bottom rather than where the last one was scrolled to
+	m.msgOffsets = nil
+
+	if m.unread[item.Id] {
+		delete(m.unread, item.Id)
+		refreshBadges(m, item.Id)
+	}
+}
+
+// findChat returns the chat with the given id
+//
+// The active chat is returned as is, any other chat is loaded from the database. nil is returned
+// if the chat no longer exists
+func findChat(m *Model, id int) *store.ChatHistory {
+	if id == m.activeChat.history.Id {
+		return m.activeChat.history
+	}
+
+	return m.repo.Find(id)
+}
+
+// recordUsage adds the tokens used by a request to the usage log for the given chat
+func recordUsage(m *Model, chatId int, usage *store.Usage) error {
+	if usage == nil {
+		return nil
+	}
+
+	return m.usageRepo.Record(&store.UsageEntry{
+		Usage:  *usage,
+		ChatId: chatId,
+	})
+}
+
+// usageFailed tells the user that the usage of a request was not recorded, the budget checks read
+// the usage log so they will undercount the spend
+func usageFailed(m *Model, history *store.ChatHistory, err error) {
+	m.appendNotice(history, "Usage could not be recorded, budgets will not include this request: %s", err)
 }
 
 // saveChat saves the active chat to the database
 // this will also update the ui with the corrected history list as specified in the database
 func saveChat(m *Model) error {
-	if m.activeChat.history.Id == 0 {
-		if err := m.repo.Create(m.activeChat.history); err != nil {
+	return saveHistory(m, m.activeChat.history)
+}
+
+// saveHistory saves the given chat to the database
+func saveHistory(m *Model, history *store.ChatHistory) error {
+	if history.Id == 0 {
+		if err := m.repo.Create(history); err != nil {
 			return err
 		}
 	} else {
-		if err := m.repo.Update(m.activeChat.history); err != nil {
+		if err := m.repo.Update(history); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// chatChanged saves the chat and updates the ui to show the change
+//
+// The ui is updated even if the chat could not be saved, the error is shown as a notice and returned
+// for the callers that can't carry on without it. A new chat is only given its id once it is saved
+func chatChanged(m *Model, history *store.ChatHistory) error {
+	err := saveHistory(m, history)
+	if err != nil {
+		m.notice = fmt.Sprintf("Error: the chat could not be saved: %s", err)
+	}
+
+	if history.Id != 0 {
+		upsertChat(m, history.ChatHistoryMeta)
+	}
+
+	if history == m.activeChat.history {
+		m.renderChat()
+	}
+
+	return err
+}
@@ -0,0 +1,152 @@
+package gpt
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/indeedhat/term-gpt/internal/store"
+)
+
+// the commands typed into the textarea to organise the chat history, they all apply to the
+// active chat apart from /tags, /filter and /starred which act on the history list
+const (
+	pinCommand     = "/pin"
+	starCommand    = "/star"
+	tagCommand     = "/tag"
+	untagCommand   = "/untag"
+	tagsCommand    = "/tags"
+	filterCommand  = "/filter"
+	starredCommand = "/starred"
+)
+
+// handleChatCommand runs the prompt if it is one of the chat history commands, false is returned
+// for any other prompt so it can be sent as normal
+func (m *Model) handleChatCommand(prompt string) bool {
+	// tags can be separated by commas as well as spaces
+	fields := strings.FieldsFunc(prompt, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ','
+	})
+	if len(fields) == 0 {
+		return false
+	}
+
+	var (
+		// changes are made to a copy so the chat is left alone if they can't be saved
+		meta = m.activeChat.history.ChatHistoryMeta
+		args = fields[1:]
+	)
+
+	switch fields[0] {
+	case pinCommand:
+		meta.Pinned = !meta.Pinned
+		if meta.Pinned {
+			m.chatMetaChanged(meta, "Pinned chat")
+		} else {
+			m.chatMetaChanged(meta, "Unpinned chat")
+		}
+	case starCommand:
+		meta.Starred = !meta.Starred
+		if meta.Starred {
+			m.chatMetaChanged(meta, "Starred chat")
+		} else {
+			m.chatMetaChanged(meta, "Unstarred chat")
+		}
+	case tagCommand:
+		if len(args) == 0 {
+			m.notice = fmt.Sprintf("Usage: %s <tag>...", tagCommand)
+			return true
+		}
+
+		meta.Tags = meta.Tags.Add(args...)
+		m.chatMetaChanged(meta, tagsNotice(meta.Tags))
+	case untagCommand:
+		// without any tags every tag is removed
+		if len(args) == 0 {
+			meta.Tags = nil
+		} else {
+			meta.Tags = meta.Tags.Remove(args...)
+		}
+
+		m.chatMetaChanged(meta, tagsNotice(meta.Tags))
+	case tagsCommand:
+		m.notice = m.tagList()
+	case filterCommand:
+		tag := ""
+		if len(args) > 0 {
+			tag = store.NormalizeTag(args[0])
+		}
+
+		// on its own /filter clears the starred filter as well
+		setListScope(m, tag, tag != "" && m.listStarred)
+		m.notice = m.scopeNotice()
+	case starredCommand:
+		setListScope(m, m.listTag, !m.listStarred)
+		m.notice = m.scopeNotice()
+	default:
+		return false
+	}
+
+	return true
+}
+
+// chatMetaChanged stores the changed pinned/starred flags and tags of the active chat and moves it
+// to its new place in the history list, notice is shown once it has been saved
+//
+// The active chat is only updated once the changes have been saved so a failed save doesn't leave
+// it showing changes that aren't in the database
+func (m *Model) chatMetaChanged(meta store.ChatHistoryMeta, notice string) {
+	// new chats are not stored until the first message is sent, the flags are saved along with it
+	if meta.Id == 0 {
+		m.activeChat.history.ChatHistoryMeta = meta
+		m.notice = notice + " (saved with the first message)"
+		return
+	}
+
+	if err := m.repo.UpdateMeta(&meta); err != nil {
+		m.notice = fmt.Sprintf("Error: %s", err)
+		return
+	}
+
+	m.activeChat.history.ChatHistoryMeta = meta
+	upsertChat(m, meta)
+	m.notice = notice
+}
+
+// scopeNotice describes the chats shown in the history list after the filter has been changed
+func (m *Model) scopeNotice() string {
+	switch {
+	case m.listTag != "" && m.listStarred:
+		return fmt.Sprintf("Showing starred chats tagged #%s", m.listTag)
+	case m.listTag != "":
+		return fmt.Sprintf("Showing chats tagged #%s", m.listTag)
+	case m.listStarred:
+		return "Showing starred chats"
+	default:
+		return "Showing all chats"
+	}
+}
+
+// tagList describes every tag in use along with how many chats use it
+func (m *Model) tagList() string {
+	tags := m.repo.Tags()
+	if len(tags) == 0 {
+		return fmt.Sprintf("No chats have been tagged yet, use %s <tag> to tag the active chat", tagCommand)
+	}
+
+	parts := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		parts = append(parts, fmt.Sprintf("#%s (%d)", tag.Tag, tag.Chats))
+	}
+
+	return "Tags: " + strings.Join(parts, " • ")
+}
+
+// tagsNotice describes the tags of the active chat after they have been changed
+func tagsNotice(tags store.Tags) string {
+	if len(tags) == 0 {
+		return "Chat has no tags"
+	}
+
+	return "Chat tagged " + tags.String()
+}
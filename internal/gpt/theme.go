@@ -0,0 +1,125 @@
+package gpt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour"
+	"github.com/indeedhat/term-gpt/internal/theme"
+)
+
+// newMarkdownRenderer creates the glamour renderer for the theme
+// a theme with a broken glamour style leaves the markdown unstyled rather than failing
+func newMarkdownRenderer(t theme.Theme) *glamour.TermRenderer {
+	md, err := glamour.NewTermRenderer(t.Markdown())
+	if err != nil {
+		return nil
+	}
+
+	return md
+}
+
+// setTheme switches the ui over to the theme, restyling every component and rendering the chat
+// again with the new colours
+func (m *Model) setTheme(t theme.Theme) {
+	m.theme = t
+	m.styles = t.Styles()
+
+	m.textarea.FocusedStyle.Prompt = m.textarea.FocusedStyle.Prompt.Copy().Foreground(m.styles.AccentColor)
+	m.spinner.Style = m.styles.Accent.Copy()
+	m.filePicker.Styles.Cursor = m.filePicker.Styles.Cursor.Copy().Foreground(m.styles.AccentColor)
+	m.filePicker.Styles.Selected = m.filePicker.Styles.Selected.Copy().Foreground(m.styles.AccentColor)
+
+	delegate := list.NewDefaultDelegate()
+	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.
+		Foreground(m.styles.AccentColor).
+		BorderForeground(m.styles.AccentColor)
+	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.
+		Foreground(m.styles.AccentColor).
+		BorderForeground(m.styles.AccentColor)
+	m.chatHistoryList.SetDelegate(historyDelegate{DefaultDelegate: delegate, styles: m.styles})
+	m.chatHistoryList.Styles.Title = m.styles.Title
+
+	m.activeChat.setTheme(t)
+
+	// refocusing sets the border colours
+	m.focusElement(m.focus)
+	m.redrawChat()
+}
+
+// themePicker is the modal used to switch themes at runtime, each theme is previewed as the
+// cursor moves over it
+type themePicker struct {
+	themes []theme.Theme
+	cursor int
+	// original is the theme to go back to if the picker is cancelled
+	original theme.Theme
+}
+
+// View renders the list of themes, height is the number of lines available
+func (p themePicker) View(styles theme.Styles, height int) string {
+	var (
+		buf  strings.Builder
+		rows = max(1, height-modalChrome)
+	)
+
+	buf.WriteString("Theme\n\n")
+
+	start := max(0, min(p.cursor-rows/2, len(p.themes)-rows))
+	for i := start; i < len(p.themes) && i < start+rows; i++ {
+		cursor := "  "
+		if i == p.cursor {
+			cursor = styles.Accent.Render("> ")
+		}
+
+		buf.WriteString(fmt.Sprintf("%s%s\n", cursor, p.themes[i].Name))
+	}
+
+	buf.WriteString("\nenter: keep • esc: cancel • up/down: preview\n")
+
+	return buf.String()
+}
+
+// openThemePicker opens the theme picker with the current theme highlighted
+// the themes are loaded again so new theme files can be tried without restarting
+func (m *Model) openThemePicker() {
+	themes, err := theme.All(m.conf.ThemeDir)
+	if err != nil {
+		m.notice = fmt.Sprintf("Error: %s", err)
+		return
+	}
+
+	p := themePicker{themes: themes, original: m.theme}
+	for i, t := range themes {
+		if t.Name == m.theme.Name {
+			p.cursor = i
+		}
+	}
+
+	m.themePicker = p
+	m.focusElement(elemTheme)
+}
+
+// handleThemeKey handles the key presses for the theme picker
+func (m *Model) handleThemeKey(msg tea.KeyMsg) {
+	p := &m.themePicker
+
+	switch msg.String() {
+	case "esc":
+		m.setTheme(p.original)
+		m.focusElement(elemTextArea)
+	case "enter":
+		m.focusElement(elemTextArea)
+		if m.theme.Name != m.conf.Theme {
+			m.notice = fmt.Sprintf("Switched to the %s theme, set theme = %q in the config file to keep it", m.theme.Name, m.theme.Name)
+		}
+	case "up", "k":
+		p.cursor = max(0, p.cursor-1)
+		m.setTheme(p.themes[p.cursor])
+	case "down", "j":
+		p.cursor = min(len(p.themes)-1, p.cursor+1)
+		m.setTheme(p.themes[p.cursor])
+	}
+}
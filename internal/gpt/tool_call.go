@@ -0,0 +1,79 @@
+package gpt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/indeedhat/term-gpt/internal/store"
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxToolOutputLines is the number of lines of tool output shown in the chat log before it is collapsed
+const maxToolOutputLines = 10
+
+// runToolCall runs a single tool call requested by the model and builds the tool result message
+// tools that require confirmation will block until the user has approved or denied the call
+func runToolCall(m *Model, chatId int, call openai.ToolCall) store.Message {
+	result := store.Message{
+		Role:       openai.ChatMessageRoleTool,
+		ToolCallID: call.ID,
+		Name:       call.Function.Name,
+	}
+
+	tool, ok := m.tools.Get(call.Function.Name)
+	if !ok {
+		result.Content = fmt.Sprintf("Error: unknown tool %s", call.Function.Name)
+		return result
+	}
+
+	if tool.RequiresConfirmation(call.Function.Arguments) && !confirmToolCall(m, chatId, call) {
+		result.Content = "Error: the user denied this tool call"
+		return result
+	}
+
+	output, err := tool.Call(m.ctx, call.Function.Arguments)
+	if err != nil {
+		result.Content = fmt.Sprintf("Error: %s", err)
+		return result
+	}
+
+	result.Content = output
+
+	return result
+}
+
+// confirmToolCall asks the user to approve the tool call and waits for their answer
+func confirmToolCall(m *Model, chatId int, call openai.ToolCall) bool {
+	reply := make(chan bool, 1)
+	m.program.Send(toolConfirmMsg{chatId: chatId, call: call, reply: reply})
+
+	select {
+	case ok := <-reply:
+		return ok
+	case <-m.ctx.Done():
+		return false
+	}
+}
+
+// renderToolCalls renders the tool calls requested by the model as a markdown list
+func renderToolCalls(calls []openai.ToolCall) string {
+	var buf strings.Builder
+
+	for _, call := range calls {
+		buf.WriteString(fmt.Sprintf("- `%s` `%s`\n", call.Function.Name, call.Function.Arguments))
+	}
+
+	return buf.String()
+}
+
+// renderToolResult renders the output of a tool call as a code block
+// long outputs are collapsed down to maxToolOutputLines lines
+func renderToolResult(msg store.Message) string {
+	lines := strings.Split(strings.TrimRight(msg.Content, "\n"), "\n")
+	if len(lines) > maxToolOutputLines {
+		hidden := len(lines) - maxToolOutputLines
+		lines = append(lines[:maxToolOutputLines], fmt.Sprintf("... (%d more lines)", hidden))
+	}
+
+	return fenced(strings.Join(lines, "\n"), "")
+}
@@ -0,0 +1,284 @@
+package keymap
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+)
+
+const (
+	PresetDefault = "default"
+	PresetVim     = "vim"
+	PresetEmacs   = "emacs"
+)
+
+// Presets lists the names of the built in key binding presets
+var Presets = []string{PresetDefault, PresetVim, PresetEmacs}
+
+// KeyMap holds all of the remappable key bindings for the app
+type KeyMap struct {
+	// Send sends the prompt in the textarea
+	Send key.Binding
+	// Newline inserts a newline into the textarea
+	Newline key.Binding
+	// Editor opens the prompt in $EDITOR
+	Editor key.Binding
+	// Resend sends the last failed prompt again
+	Resend key.Binding
+	// Settings opens the sampling settings for the active chat
+	Settings key.Binding
+	// Servers toggles the list of MCP servers
+	Servers key.Binding
+	// Focus toggles the focus between the textarea and the chat history
+	Focus key.Binding
+	// Select opens the highlighted chat in the chat history
+	Select key.Binding
+	// ScrollUp scrolls the chat up by one line
+	ScrollUp key.Binding
+	// ScrollDown scrolls the chat down by one line
+	ScrollDown key.Binding
+	// PageUp scrolls the chat up by one page
+	PageUp key.Binding
+	// PageDown scrolls the chat down by one page
+	PageDown key.Binding
+	// HalfPageUp scrolls the chat up by half a page
+	HalfPageUp key.Binding
+	// HalfPageDown scrolls the chat down by half a page
+	HalfPageDown key.Binding
+	// JumpPrev scrolls the chat to the start of the previous message
+	JumpPrev key.Binding
+	// JumpNext scrolls the chat to the start of the next message
+	JumpNext key.Binding
+	// Top scrolls to the top of the chat
+	Top key.Binding
+	// Bottom scrolls to the bottom of the chat
+	Bottom key.Binding
+	// SelectMode toggles message selection mode in the chat viewport
+	SelectMode key.Binding
+	// PrevMessage selects the previous message in selection mode
+	PrevMessage key.Binding
+	// NextMessage selects the next message in selection mode
+	NextMessage key.Binding
+	// Copy copies the selected message to the clipboard
+	Copy key.Binding
+	// CopyCode copies a code block from the selected message, the nth key copies the nth block
+	CopyCode key.Binding
+	// SaveCode opens the picker used to save a code block from the chat to a file
+	SaveCode key.Binding
+	// ApplyDiff opens the picker used to apply a diff from the chat to the working tree
+	ApplyDiff key.Binding
+	// Theme opens the theme picker
+	Theme key.Binding
+	// ToggleSidebar hides or shows the chat history sidebar
+	ToggleSidebar key.Binding
+	// ShrinkSidebar makes the chat history sidebar narrower
+	ShrinkSidebar key.Binding
+	// GrowSidebar makes the chat history sidebar wider
+	GrowSidebar key.Binding
+	// Help toggles the help overlay
+	Help key.Binding
+	// Quit closes the app
+	Quit key.Binding
+}
+
+// action describes a single remappable key binding
+type action struct {
+	// name is the name used to refer to the binding in the config file
+	name    string
+	binding func(*KeyMap) *key.Binding
+	help    string
+}
+
+var actions = []action{
+	{"send", func(k *KeyMap) *key.Binding { return &k.Send }, "send prompt"},
+	{"newline", func(k *KeyMap) *key.Binding { return &k.Newline }, "insert newline"},
+	{"editor", func(k *KeyMap) *key.Binding { return &k.Editor }, "open prompt in $EDITOR"},
+	{"resend", func(k *KeyMap) *key.Binding { return &k.Resend }, "resend failed prompt"},
+	{"settings", func(k *KeyMap) *key.Binding { return &k.Settings }, "chat settings"},
+	{"servers", func(k *KeyMap) *key.Binding { return &k.Servers }, "MCP servers"},
+	{"focus", func(k *KeyMap) *key.Binding { return &k.Focus }, "switch pane"},
+	{"select", func(k *KeyMap) *key.Binding { return &k.Select }, "open chat"},
+	{"scroll_up", func(k *KeyMap) *key.Binding { return &k.ScrollUp }, "scroll chat up"},
+	{"scroll_down", func(k *KeyMap) *key.Binding { return &k.ScrollDown }, "scroll chat down"},
+	{"page_up", func(k *KeyMap) *key.Binding { return &k.PageUp }, "page up"},
+	{"page_down", func(k *KeyMap) *key.Binding { return &k.PageDown }, "page down"},
+	{"half_page_up", func(k *KeyMap) *key.Binding { return &k.HalfPageUp }, "half page up"},
+	{"half_page_down", func(k *KeyMap) *key.Binding { return &k.HalfPageDown }, "half page down"},
+	{"jump_prev", func(k *KeyMap) *key.Binding { return &k.JumpPrev }, "previous message"},
+	{"jump_next", func(k *KeyMap) *key.Binding { return &k.JumpNext }, "next message"},
+	{"top", func(k *KeyMap) *key.Binding { return &k.Top }, "top of chat"},
+	{"bottom", func(k *KeyMap) *key.Binding { return &k.Bottom }, "bottom of chat"},
+	{"select_mode", func(k *KeyMap) *key.Binding { return &k.SelectMode }, "select messages"},
+	{"prev_message", func(k *KeyMap) *key.Binding { return &k.PrevMessage }, "previous message"},
+	{"next_message", func(k *KeyMap) *key.Binding { return &k.NextMessage }, "next message"},
+	{"copy", func(k *KeyMap) *key.Binding { return &k.Copy }, "copy message"},
+	{"copy_code", func(k *KeyMap) *key.Binding { return &k.CopyCode }, "copy code block"},
+	{"save_code", func(k *KeyMap) *key.Binding { return &k.SaveCode }, "save code block"},
+	{"apply_diff", func(k *KeyMap) *key.Binding { return &k.ApplyDiff }, "apply diff"},
+	{"theme", func(k *KeyMap) *key.Binding { return &k.Theme }, "switch theme"},
+	{"toggle_sidebar", func(k *KeyMap) *key.Binding { return &k.ToggleSidebar }, "toggle sidebar"},
+	{"shrink_sidebar", func(k *KeyMap) *key.Binding { return &k.ShrinkSidebar }, "shrink sidebar"},
+	{"grow_sidebar", func(k *KeyMap) *key.Binding { return &k.GrowSidebar }, "grow sidebar"},
+	{"help", func(k *KeyMap) *key.Binding { return &k.Help }, "toggle help (? on an empty prompt)"},
+	{"quit", func(k *KeyMap) *key.Binding { return &k.Quit }, "quit"},
+}
+
+var defaultKeys = map[string][]string{
+	"send":           {"enter"},
+	"newline":        {"alt+enter", "ctrl+j", "enter"},
+	"editor":         {"ctrl+o"},
+	"resend":         {"ctrl+r"},
+	"settings":       {"alt+s"},
+	"servers":        {"ctrl+g"},
+	"focus":          {"tab"},
+	"select":         {"enter"},
+	"scroll_up":      {"ctrl+p"},
+	"scroll_down":    {"ctrl+n"},
+	"page_up":        {"pgup"},
+	"page_down":      {"pgdown"},
+	"half_page_up":   {"ctrl+pgup"},
+	"half_page_down": {"ctrl+pgdown"},
+	"jump_prev":      {"alt+up"},
+	"jump_next":      {"alt+down"},
+	"top":            {"ctrl+home"},
+	"bottom":         {"ctrl+end"},
+	"select_mode":    {"alt+v"},
+	"prev_message":   {"up", "k"},
+	"next_message":   {"down", "j"},
+	"copy":           {"y"},
+	"copy_code":      {"1", "2", "3", "4", "5", "6", "7", "8", "9"},
+	"save_code":      {"ctrl+s"},
+	"apply_diff":     {"alt+a"},
+	"theme":          {"alt+t"},
+	"toggle_sidebar": {"alt+h"},
+	"shrink_sidebar": {"alt+-"},
+	"grow_sidebar":   {"alt+="},
+	"help":           {"?", "f1"},
+	"quit":           {"ctrl+c"},
+}
+
+// presets contains the bindings each preset changes from the defaults
+var presets = map[string]map[string][]string{
+	PresetDefault: {},
+	// g, G, [ and ] are typed into the prompt as normal, they only move the chat in selection mode
+	PresetVim: {
+		"scroll_up":      {"ctrl+y", "ctrl+p"},
+		"scroll_down":    {"ctrl+e", "ctrl+n"},
+		"page_up":        {"pgup", "ctrl+b"},
+		"page_down":      {"pgdown", "ctrl+f"},
+		"half_page_up":   {"ctrl+pgup", "ctrl+u"},
+		"half_page_down": {"ctrl+pgdown", "ctrl+d"},
+		"jump_prev":      {"alt+up", "["},
+		"jump_next":      {"alt+down", "]"},
+		"top":            {"ctrl+home", "g"},
+		"bottom":         {"ctrl+end", "G"},
+		"focus":          {"tab", "ctrl+w"},
+		"select":         {"enter", "l"},
+	},
+	PresetEmacs: {
+		"scroll_up":    {"alt+p"},
+		"scroll_down":  {"alt+n"},
+		"jump_prev":    {"alt+up", "alt+{"},
+		"jump_next":    {"alt+down", "alt+}"},
+		"top":          {"ctrl+home", "alt+<"},
+		"bottom":       {"ctrl+end", "alt+>"},
+		"focus":        {"alt+o", "tab"},
+		"prev_message": {"up", "ctrl+p"},
+		"next_message": {"down", "ctrl+n"},
+		"copy":         {"alt+w"},
+		"editor":       {"ctrl+x"},
+		"quit":         {"ctrl+c", "ctrl+q"},
+	},
+}
+
+// New builds the key map from a preset with the user bindings applied over the top
+//
+// sendKey is the legacy send_key setting, it is applied before the bindings so it can still be
+// overridden by them. Any send keys are removed from the newline binding so that the two never clash
+func New(preset, sendKey string, bindings map[string][]string) (KeyMap, error) {
+	if preset == "" {
+		preset = PresetDefault
+	}
+
+	overlay, ok := presets[preset]
+	if !ok {
+		return KeyMap{}, fmt.Errorf("unknown key preset %q, must be one of %s", preset, strings.Join(Presets, ", "))
+	}
+
+	keys := make(map[string][]string, len(defaultKeys))
+	for name, k := range defaultKeys {
+		keys[name] = k
+	}
+	for name, k := range overlay {
+		keys[name] = k
+	}
+	if sendKey != "" {
+		keys["send"] = []string{sendKey}
+	}
+
+	var unknown []string
+	for name, k := range bindings {
+		if _, ok := keys[name]; !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		keys[name] = k
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return KeyMap{}, fmt.Errorf("unknown key bindings: %s", strings.Join(unknown, ", "))
+	}
+
+	keys["newline"] = slices.DeleteFunc(slices.Clone(keys["newline"]), func(k string) bool {
+		return slices.Contains(keys["send"], k)
+	})
+	if len(keys["send"]) == 0 {
+		return KeyMap{}, errors.New("the send binding must have at least one key")
+	}
+
+	var km KeyMap
+	for _, a := range actions {
+		*a.binding(&km) = key.NewBinding(
+			key.WithKeys(keys[a.name]...),
+			key.WithHelp(helpKey(keys[a.name]), a.help),
+		)
+	}
+
+	return km, nil
+}
+
+// helpKey formats the keys of a binding for the help overlay, long lists of keys (such as the
+// copy_code digits) are shortened to a range
+func helpKey(keys []string) string {
+	if len(keys) > 3 {
+		return keys[0] + "-" + keys[len(keys)-1]
+	}
+
+	return strings.Join(keys, "/")
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Send, k.Focus, k.Help, k.Quit}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Send, k.Newline, k.Editor, k.Resend},
+		{k.Focus, k.Select, k.ScrollUp, k.ScrollDown},
+		{k.PageUp, k.PageDown, k.HalfPageUp, k.HalfPageDown, k.JumpPrev, k.JumpNext, k.Top, k.Bottom},
+		{k.SelectMode, k.PrevMessage, k.NextMessage, k.Copy, k.CopyCode},
+		{k.SaveCode, k.ApplyDiff},
+		{k.ToggleSidebar, k.ShrinkSidebar, k.GrowSidebar, k.Theme},
+		{k.Settings, k.Servers, k.Help, k.Quit},
+	}
+}
+
+var _ help.KeyMap = (*KeyMap)(nil)
@@ -0,0 +1,76 @@
+package keymap
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	for _, preset := range Presets {
+		t.Run(preset, func(t *testing.T) {
+			for name := range presets[preset] {
+				if _, ok := defaultKeys[name]; !ok {
+					t.Errorf("preset changes unknown binding %s", name)
+				}
+			}
+
+			km, err := New(preset, "", nil)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			for _, a := range actions {
+				if len(a.binding(&km).Keys()) == 0 {
+					t.Errorf("%s has no keys", a.name)
+				}
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		name     string
+		preset   string
+		sendKey  string
+		bindings map[string][]string
+		err      bool
+		send     []string
+		newline  []string
+	}{
+		{name: "default", send: []string{"enter"}, newline: []string{"alt+enter", "ctrl+j"}},
+		{name: "send key", sendKey: "ctrl+s", send: []string{"ctrl+s"}, newline: []string{"alt+enter", "ctrl+j", "enter"}},
+		{
+			name:     "bindings win over the send key",
+			sendKey:  "ctrl+s",
+			bindings: map[string][]string{"send": {"ctrl+j"}},
+			send:     []string{"ctrl+j"},
+			newline:  []string{"alt+enter", "enter"},
+		},
+		{name: "unknown preset", preset: "nano", err: true},
+		{name: "unknown binding", bindings: map[string][]string{"launch": {"x"}}, err: true},
+		{name: "no send keys", bindings: map[string][]string{"send": {}}, err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			km, err := New(c.preset, c.sendKey, c.bindings)
+			if c.err {
+				if err == nil {
+					t.Fatal("New() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			if got := km.Send.Keys(); !slices.Equal(got, c.send) {
+				t.Errorf("send keys = %v, want %v", got, c.send)
+			}
+			if got := km.Newline.Keys(); !slices.Equal(got, c.newline) {
+				t.Errorf("newline keys = %v, want %v", got, c.newline)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	protocolVersion = "2024-11-05"
+	clientName      = "term-gpt"
+	clientVersion   = "0.1.0"
+
+	// connectTimeout is the amount of time a server has to complete the initialize handshake
+	connectTimeout = 10 * time.Second
+)
+
+var ErrClosed = errors.New("mcp server connection closed")
+
+// Client is a connection to a single MCP server running as a child process and communicating
+// over stdio using newline delimited JSON-RPC 2.0 messages
+type Client struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// writeMu keeps messages written to stdin from interleaving, it is separate from mu so that a
+	// server which has stopped reading can't block readLoop from routing responses
+	writeMu sync.Mutex
+
+	// mu guards nextID, pending and closed
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan response
+	closed  bool
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+type request struct {
+	JsonRpc string `json:"jsonrpc"`
+	ID      *int   `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type response struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements error.
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// Start launches the server process and performs the initialize handshake
+func Start(ctx context.Context, conf ServerConfig) (*Client, error) {
+	cmd := exec.Command(conf.Command, conf.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range conf.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int]chan response),
+	}
+
+	go c.readLoop(stdout)
+
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// initialize performs the MCP initialize handshake
+func (c *Client) initialize(ctx context.Context) error {
+	params := map[string]any{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo": map[string]string{
+			"name":    clientName,
+			"version": clientVersion,
+		},
+	}
+
+	if _, err := c.call(ctx, "initialize", params); err != nil {
+		return fmt.Errorf("initialize failed: %w", err)
+	}
+
+	return c.notify("notifications/initialized")
+}
+
+// ListTools returns all the tools exposed by the server
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	var (
+		tools  []ToolInfo
+		cursor string
+	)
+
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+
+		raw, err := c.call(ctx, "tools/list", params)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, err
+		}
+
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool runs a tool on the server and returns the text content of its result
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+
+	raw, err := c.call(ctx, "tools/call", map[string]any{
+		"name":      name,
+		"arguments": args,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return "", err
+	}
+
+	var text string
+	for _, part := range result.Content {
+		if part.Type == "text" {
+			text += part.Text
+		} else {
+			text += fmt.Sprintf("[%s content omitted]", part.Type)
+		}
+	}
+
+	if result.IsError {
+		return "", errors.New(text)
+	}
+
+	return text, nil
+}
+
+// Close shuts down the server process
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.closed = true
+		c.mu.Unlock()
+
+		c.stdin.Close()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- c.cmd.Wait()
+		}()
+
+		select {
+		case c.closeErr = <-done:
+		case <-time.After(time.Second):
+			c.cmd.Process.Kill()
+			c.closeErr = <-done
+		}
+	})
+
+	return c.closeErr
+}
+
+// call sends a request to the server and waits for its response
+func (c *Client) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+
+	c.nextID++
+	id := c.nextID
+	ch := make(chan response, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(request{JsonRpc: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, ErrClosed
+		}
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+
+		return resp.Result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// notify sends a notification to the server, notifications do not get a response
+func (c *Client) notify(method string) error {
+	return c.write(request{JsonRpc: "2.0", Method: method})
+}
+
+// write encodes a single message onto the servers stdin
+func (c *Client) write(req request) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_, err = c.stdin.Write(append(data, '\n'))
+
+	return err
+}
+
+// readLoop reads messages from the servers stdout and routes responses to the waiting caller
+// requests and notifications from the server are ignored
+func (c *Client) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var resp response
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil || resp.ID == nil || resp.Method != "" {
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[*resp.ID]
+		c.mu.Unlock()
+
+		if !ok {
+			continue
+		}
+
+		select {
+		case ch <- resp:
+		default:
+		}
+	}
+
+	// the server has gone away so fail anything still waiting on a response
+	c.mu.Lock()
+	c.closed = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+}
@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ServerConfig describes how to launch a single MCP server
+type ServerConfig struct {
+	// Name is used to namespace the tools exposed by the server
+	Name    string            `json:"-"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+}
+
+// LoadConfig reads the MCP server definitions from the json file at path
+//
+// The file uses the same layout as other MCP clients:
+//
+//	{"mcpServers": {"name": {"command": "...", "args": [], "env": {}}}}
+//
+// A missing file is not an error, it just means no servers are configured
+func LoadConfig(path string) ([]ServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var conf struct {
+		Servers map[string]ServerConfig `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("invalid mcp config %s: %w", path, err)
+	}
+
+	servers := make([]ServerConfig, 0, len(conf.Servers))
+	for name, server := range conf.Servers {
+		if server.Command == "" {
+			return nil, fmt.Errorf("mcp server %s has no command", name)
+		}
+
+		server.Name = name
+		servers = append(servers, server)
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].Name < servers[j].Name
+	})
+
+	return servers, nil
+}
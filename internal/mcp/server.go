@@ -0,0 +1,162 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+
+	"github.com/indeedhat/term-gpt/internal/tools"
+)
+
+const (
+	// maxToolNameLength is the longest function name accepted by the openai API
+	maxToolNameLength = 64
+	// toolNameHashLength is the number of hex characters of the hash added to changed tool names
+	toolNameHashLength = 8
+)
+
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// ToolInfo describes a tool as reported by an MCP server
+type ToolInfo struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// Server is a configured MCP server along with its connection state
+type Server struct {
+	Name string
+	// Tools holds the tools discovered on the server
+	Tools []ToolInfo
+	// Err is set if the server could not be started or its tools could not be listed, it is also
+	// set for a connected server if any of its tools could not be registered
+	Err error
+
+	client *Client
+}
+
+// Connected reports if the server was started successfully
+func (s *Server) Connected() bool {
+	return s.client != nil
+}
+
+// Close shuts down the server process
+func (s *Server) Close() error {
+	if s.client == nil {
+		return nil
+	}
+
+	return s.client.Close()
+}
+
+// ToolSet wraps each of the servers tools so it can be added to a tools.Registry
+func (s *Server) ToolSet() []tools.Tool {
+	set := make([]tools.Tool, 0, len(s.Tools))
+
+	for _, info := range s.Tools {
+		set = append(set, Tool{server: s, info: info})
+	}
+
+	return set
+}
+
+// Register adds the servers tools to the registry
+// tools with the same name as one that is already registered are left out and reported in Err
+func (s *Server) Register(registry *tools.Registry) {
+	for _, tool := range s.ToolSet() {
+		if err := registry.Register(tool); err != nil {
+			s.Err = errors.Join(s.Err, err)
+		}
+	}
+}
+
+// ConnectAll starts each of the configured servers and discovers their tools
+//
+// A server that fails to start is still returned with its Err set so it can be reported to the user
+func ConnectAll(ctx context.Context, configs []ServerConfig) []*Server {
+	servers := make([]*Server, 0, len(configs))
+
+	for _, conf := range configs {
+		server := &Server{Name: conf.Name}
+		servers = append(servers, server)
+
+		client, err := Start(ctx, conf)
+		if err != nil {
+			server.Err = err
+			continue
+		}
+
+		listCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+		server.Tools, server.Err = client.ListTools(listCtx)
+		cancel()
+
+		if server.Err != nil {
+			client.Close()
+			continue
+		}
+
+		server.client = client
+	}
+
+	return servers
+}
+
+// Tool adapts a tool exposed by an MCP server to the tools.Tool interface
+type Tool struct {
+	server *Server
+	info   ToolInfo
+}
+
+// Name implements tools.Tool.
+// The tool name is namespaced by the server name to avoid collisions between servers
+//
+// Names that have to be changed to be accepted by the API are given a hash of the original name so
+// they don't collide with each other, a.b and a_b for example
+func (t Tool) Name() string {
+	full := t.server.Name + "__" + t.info.Name
+
+	name := invalidToolNameChars.ReplaceAllString(full, "_")
+	if name == full && len(name) <= maxToolNameLength {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(full))
+	suffix := "_" + hex.EncodeToString(sum[:])[:toolNameHashLength]
+
+	return name[:min(len(name), maxToolNameLength-len(suffix))] + suffix
+}
+
+// Description implements tools.Tool.
+func (t Tool) Description() string {
+	return t.info.Description
+}
+
+// Parameters implements tools.Tool.
+func (t Tool) Parameters() any {
+	if len(t.info.InputSchema) == 0 {
+		return map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+
+	return t.info.InputSchema
+}
+
+// RequiresConfirmation implements tools.Tool.
+// MCP tools can do anything so every call needs to be approved
+func (t Tool) RequiresConfirmation(string) bool {
+	return true
+}
+
+// Call implements tools.Tool.
+func (t Tool) Call(ctx context.Context, args string) (string, error) {
+	if t.server.client == nil {
+		return "", ErrClosed
+	}
+
+	return t.server.client.CallTool(ctx, t.info.Name, json.RawMessage(args))
+}
+
+var _ tools.Tool = (*Tool)(nil)
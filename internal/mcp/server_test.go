@@ -0,0 +1,68 @@
+package mcp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/indeedhat/term-gpt/internal/tools"
+)
+
+func TestToolName(t *testing.T) {
+	long := strings.Repeat("x", 80)
+
+	cases := []struct {
+		name   string
+		server string
+		tool   string
+		// want is the whole name, or everything before the hash if hashed is set
+		want   string
+		hashed bool
+	}{
+		{"valid name is kept", "fs", "read_file", "fs__read_file", false},
+		{"changed name is hashed", "fs", "read.file", "fs__read_file_", true},
+		{"long name is hashed", "fs", long, "fs__" + long[:maxToolNameLength-len("fs___")-toolNameHashLength] + "_", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Tool{server: &Server{Name: c.server}, info: ToolInfo{Name: c.tool}}.Name()
+
+			want := len(c.want)
+			if c.hashed {
+				want += toolNameHashLength
+			}
+			if !strings.HasPrefix(got, c.want) || len(got) != want {
+				t.Errorf("Name() = %q, want %q (hashed %v)", got, c.want, c.hashed)
+			}
+			if len(got) > maxToolNameLength || invalidToolNameChars.MatchString(got) {
+				t.Errorf("Name() = %q is not a valid function name", got)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var (
+		registry = tools.NewRegistry()
+		first    = &Server{Name: "x", Tools: []ToolInfo{{Name: "y__z"}, {Name: "a.b"}, {Name: "a_b"}}}
+		second   = &Server{Name: "x__y", Tools: []ToolInfo{{Name: "z"}, {Name: "w"}}}
+	)
+
+	first.Register(registry)
+	second.Register(registry)
+
+	if first.Err != nil {
+		t.Errorf("first server Err = %v, want nil", first.Err)
+	}
+	if !errors.Is(second.Err, tools.ErrDuplicateTool) {
+		t.Errorf("second server Err = %v, want %v", second.Err, tools.ErrDuplicateTool)
+	}
+
+	if got := len(registry.List()); got != 4 {
+		t.Errorf("registered %d tools, want 4", got)
+	}
+	if tool, _ := registry.Get("x__y__z"); tool.(Tool).server != first {
+		t.Error("x__y__z was replaced by the second server")
+	}
+}
@@ -0,0 +1,251 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/indeedhat/term-gpt/internal/diff"
+	"github.com/indeedhat/term-gpt/internal/workdir"
+)
+
+// HunkResult reports if a single hunk could be applied
+type HunkResult struct {
+	// Line is the line of the original file the hunk was applied at
+	Line int
+	// Offset is the number of lines the hunk moved from where the header said it would be
+	Offset int
+	// Fuzzy is set if the hunk only matched when ignoring whitespace
+	Fuzzy bool
+	Err   error
+}
+
+// Result reports the outcome of applying the patch to a single file
+type Result struct {
+	File  File
+	Hunks []HunkResult
+	// Err is set if the patch could not be applied to the file at all
+	Err error
+
+	content string
+}
+
+// Applied is the number of hunks that were applied
+func (r Result) Applied() int {
+	var n int
+	for _, hunk := range r.Hunks {
+		if hunk.Err == nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Failed is the number of hunks that could not be applied
+func (r Result) Failed() int {
+	if r.Err != nil {
+		return len(r.File.Hunks)
+	}
+
+	return len(r.Hunks) - r.Applied()
+}
+
+// Clean reports if every hunk of every file in the results was applied
+func Clean(results []Result) bool {
+	for _, r := range results {
+		if r.Failed() > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Check dry runs the patch against the files on disk, nothing is written
+//
+// The paths come from the patch so any that are absolute or lead outside of the working directory
+// are rejected rather than read, symlinks are followed so a linked directory can't be used to get
+// out of it either. They are reported as a failed file
+func Check(files []File) []Result {
+	results := make([]Result, 0, len(files))
+
+	for _, file := range files {
+		result := Result{File: file}
+
+		if !workdir.Contains(file.Path()) {
+			result.Err = fmt.Errorf("%s is outside of the working directory", file.Path())
+			results = append(results, result)
+			continue
+		}
+
+		data, err := os.ReadFile(file.Path())
+		switch {
+		case file.IsNew() && err == nil:
+			result.Err = fmt.Errorf("%s already exists", file.Path())
+		case file.IsNew() && errors.Is(err, os.ErrNotExist):
+			result.content, result.Hunks = Apply("", file.Hunks)
+		case err != nil:
+			result.Err = err
+		default:
+			result.content, result.Hunks = Apply(string(data), file.Hunks)
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// Write saves the patched files from a dry run, files with no hunks applied are left alone
+// deleted files are removed once all of their lines have been removed
+func Write(results []Result) error {
+	var errs []error
+
+	for _, r := range results {
+		// results that didn't come from Check are held to the same rule on paths
+		path := r.File.Path()
+		if r.Err != nil || r.Applied() == 0 || !workdir.Contains(path) {
+			continue
+		}
+
+		if r.File.IsDelete() && r.Failed() == 0 && r.content == "" {
+			if err := os.Remove(path); err != nil {
+				errs = append(errs, err)
+			}
+
+			continue
+		}
+
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := os.WriteFile(path, []byte(r.content), mode); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Apply applies the hunks to content in order, hunks that can't be placed are skipped and
+// reported in the results
+//
+// Each hunk is looked for where its header says it should be, adjusted for the lines added and
+// removed by earlier hunks, and then further and further away from there. If the context lines
+// don't match exactly they are compared again ignoring leading and trailing whitespace
+func Apply(content string, hunks []diff.Hunk) (string, []HunkResult) {
+	var (
+		lines   = diff.SplitLines(content)
+		results = make([]HunkResult, 0, len(hunks))
+		// minPos stops hunks from being applied over the top of earlier ones
+		minPos int
+		shift  int
+	)
+
+	for _, hunk := range hunks {
+		var old []string
+		for _, e := range hunk.Edits {
+			if e.Op != diff.Insert {
+				old = append(old, e.Text)
+			}
+		}
+
+		expected := minPos
+		if hunk.OldStart > 0 {
+			expected = hunk.OldStart - 1 + shift
+		}
+
+		pos, fuzzy := find(lines, old, expected, minPos)
+		if pos < 0 {
+			results = append(results, HunkResult{
+				Err: fmt.Errorf("context not found near line %d", max(1, hunk.OldStart)),
+			})
+			continue
+		}
+
+		result := HunkResult{Line: pos - shift + 1, Fuzzy: fuzzy}
+		if hunk.OldStart > 0 {
+			result.Offset = pos - expected
+		}
+		results = append(results, result)
+
+		// context lines keep the text from the file so a fuzzy match doesn't change their whitespace
+		replacement := make([]string, 0, hunk.NewLines)
+		i := pos
+		for _, e := range hunk.Edits {
+			switch e.Op {
+			case diff.Equal:
+				replacement = append(replacement, lines[i])
+				i++
+			case diff.Delete:
+				i++
+			case diff.Insert:
+				replacement = append(replacement, e.Text)
+			}
+		}
+
+		lines = append(lines[:pos:pos], append(replacement, lines[pos+len(old):]...)...)
+		minPos = pos + len(replacement)
+		shift += len(replacement) - len(old)
+	}
+
+	if len(lines) == 0 {
+		return "", results
+	}
+
+	return strings.Join(lines, "\n") + "\n", results
+}
+
+// find looks for the old lines in the file starting at the expected position and moving outwards
+// -1 is returned if they can't be found at or after minPos
+func find(lines, old []string, expected, minPos int) (pos int, fuzzy bool) {
+	last := len(lines) - len(old)
+	if last < minPos {
+		return -1, false
+	}
+
+	expected = max(minPos, min(last, expected))
+
+	for i, equal := range []func(a, b string) bool{exact, trimmed} {
+		for d := 0; expected-d >= minPos || expected+d <= last; d++ {
+			for _, pos := range []int{expected - d, expected + d} {
+				if pos >= minPos && pos <= last && matches(lines[pos:pos+len(old)], old, equal) {
+					return pos, i > 0
+				}
+			}
+		}
+	}
+
+	return -1, false
+}
+
+// matches compares each of the lines using equal
+func matches(lines, old []string, equal func(a, b string) bool) bool {
+	for i := range old {
+		if !equal(lines[i], old[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// exact compares lines as is
+func exact(a, b string) bool {
+	return a == b
+}
+
+// trimmed compares lines ignoring leading and trailing whitespace
+func trimmed(a, b string) bool {
+	return strings.TrimSpace(a) == strings.TrimSpace(b)
+}
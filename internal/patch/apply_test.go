@@ -0,0 +1,195 @@
+package patch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/indeedhat/term-gpt/internal/diff"
+)
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		hunks   []diff.Hunk
+		want    string
+		results []HunkResult
+	}{
+		{
+			name:    "exact match",
+			content: "a\nb\nc\n",
+			hunks:   []diff.Hunk{hunk(1, 1, " a", "-b", "+B", " c")},
+			want:    "a\nB\nc\n",
+			results: []HunkResult{{Line: 1}},
+		},
+		{
+			name:    "offset",
+			content: "x\ny\na\nb\nc\n",
+			hunks:   []diff.Hunk{hunk(1, 1, " a", "-b", "+B", " c")},
+			want:    "x\ny\na\nB\nc\n",
+			results: []HunkResult{{Line: 3, Offset: 2}},
+		},
+		{
+			name:    "negative offset",
+			content: "a\nb\nc\nd\n",
+			hunks:   []diff.Hunk{hunk(4, 4, " b", "-c", "+C")},
+			want:    "a\nb\nC\nd\n",
+			results: []HunkResult{{Line: 2, Offset: -2}},
+		},
+		{
+			name:    "whitespace fuzzy",
+			content: "func main() {\n\treturn\n}\n",
+			hunks:   []diff.Hunk{hunk(1, 1, " func main() {", "-    return", "+\tos.Exit(1)", " }")},
+			want:    "func main() {\n\tos.Exit(1)\n}\n",
+			results: []HunkResult{{Line: 1, Fuzzy: true}},
+		},
+		{
+			name:    "whitespace fuzzy with offset",
+			content: "package main\n\nfunc main() {\n\treturn\n}\n",
+			hunks:   []diff.Hunk{hunk(1, 1, " func main() {  ", "-  return", "+\tos.Exit(1)")},
+			want:    "package main\n\nfunc main() {\n\tos.Exit(1)\n}\n",
+			results: []HunkResult{{Line: 3, Offset: 2, Fuzzy: true}},
+		},
+		{
+			name:    "bare header searches the whole file",
+			content: "a\nb\nc\nd\ne\n",
+			hunks:   []diff.Hunk{hunk(0, 0, " d", "+D", " e")},
+			want:    "a\nb\nc\nd\nD\ne\n",
+			results: []HunkResult{{Line: 4}},
+		},
+		{
+			name:    "later hunks are shifted by earlier ones",
+			content: "a\nb\nc\nd\ne\nf\n",
+			hunks: []diff.Hunk{
+				hunk(1, 1, " a", "+a2", "+a3", " b"),
+				hunk(5, 7, " e", "-f", "+F"),
+			},
+			want:    "a\na2\na3\nb\nc\nd\ne\nF\n",
+			results: []HunkResult{{Line: 1}, {Line: 5}},
+		},
+		{
+			name:    "failed hunks are skipped",
+			content: "a\nb\nc\n",
+			hunks: []diff.Hunk{
+				hunk(1, 1, " x", "-y", "+z"),
+				hunk(3, 3, "-c", "+C"),
+			},
+			want:    "a\nb\nC\n",
+			results: []HunkResult{{Err: errors.New("context not found near line 1")}, {Line: 3}},
+		},
+		{
+			name:    "new file",
+			content: "",
+			hunks:   []diff.Hunk{hunk(1, 1, "+one", "+two")},
+			want:    "one\ntwo\n",
+			results: []HunkResult{{Line: 1}},
+		},
+		{
+			name:    "every line removed",
+			content: "one\ntwo\n",
+			hunks:   []diff.Hunk{hunk(1, 1, "-one", "-two")},
+			want:    "",
+			results: []HunkResult{{Line: 1}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, results := Apply(c.content, c.hunks)
+			if got != c.want {
+				t.Errorf("Apply() content = %q, want %q", got, c.want)
+			}
+
+			if !reflect.DeepEqual(results, c.results) {
+				t.Errorf("Apply() results = %+v, want %+v", results, c.results)
+			}
+		})
+	}
+}
+
+// chdirTemp moves into a new temp directory holding files, the working directory is restored when
+// the test ends
+func chdirTemp(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestCheckAndWrite(t *testing.T) {
+	chdirTemp(t, map[string]string{
+		"edit.txt":   "a\nb\nc\n",
+		"delete.txt": "one\ntwo\n",
+		"exists.txt": "here\n",
+	})
+	if err := os.Symlink(os.TempDir(), "tmp"); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := Parse("--- a/edit.txt\n+++ b/edit.txt\n@@ -2 +2 @@\n-b\n+B\n" +
+		"--- /dev/null\n+++ b/dir/new.txt\n@@ -0,0 +1 @@\n+new\n" +
+		"--- a/delete.txt\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-one\n-two\n" +
+		"--- /dev/null\n+++ b/exists.txt\n@@ -0,0 +1 @@\n+new\n" +
+		"--- a/missing.txt\n+++ b/missing.txt\n@@ -1 +1 @@\n-a\n+b\n" +
+		"--- a/../outside.txt\n+++ b/../outside.txt\n@@ -1 +1 @@\n-a\n+b\n" +
+		"--- /dev/null\n+++ b/tmp/linked.txt\n@@ -0,0 +1 @@\n+new\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := Check(files)
+
+	wantErrs := map[string]bool{
+		"edit.txt":       false,
+		"dir/new.txt":    false,
+		"delete.txt":     false,
+		"exists.txt":     true,
+		"missing.txt":    true,
+		"../outside.txt": true,
+		"tmp/linked.txt": true,
+	}
+	for _, r := range results {
+		if got := r.Err != nil; got != wantErrs[r.File.Path()] {
+			t.Errorf("Check() %s error = %v, want error %v", r.File.Path(), r.Err, wantErrs[r.File.Path()])
+		}
+	}
+	if Clean(results) {
+		t.Error("Clean() = true with failed files")
+	}
+
+	if err := Write(results); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"edit.txt":    "a\nB\nc\n",
+		"dir/new.txt": "new\n",
+		"exists.txt":  "here\n",
+	} {
+		if data, err := os.ReadFile(path); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v want %q", path, data, err, want)
+		}
+	}
+
+	for _, path := range []string{"delete.txt", "tmp/linked.txt"} {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s exists after Write()", path)
+		}
+	}
+}
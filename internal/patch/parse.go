@@ -0,0 +1,228 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/indeedhat/term-gpt/internal/diff"
+)
+
+// devNull is the name used in place of the old file for new files and the new file for deleted files
+const devNull = "/dev/null"
+
+// ErrNoDiff is returned when the text does not contain a unified diff
+var ErrNoDiff = errors.New("no unified diff found")
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// File is the set of changes made to a single file by a patch
+type File struct {
+	// OldName and NewName are the paths from the --- and +++ lines with any a/ b/ prefix removed
+	// they are empty for new and deleted files respectively
+	OldName string
+	NewName string
+	Hunks   []diff.Hunk
+}
+
+// Path is the path of the file the patch changes
+func (f File) Path() string {
+	if f.NewName == "" {
+		return f.OldName
+	}
+
+	return f.NewName
+}
+
+// IsNew reports if the patch creates the file
+func (f File) IsNew() bool {
+	return f.OldName == ""
+}
+
+// IsDelete reports if the patch deletes the file
+func (f File) IsDelete() bool {
+	return f.NewName == ""
+}
+
+// Looks reports if the text looks like a unified diff without fully parsing it
+func Looks(text string) bool {
+	var header bool
+
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "), strings.HasPrefix(line, "+++ "):
+			header = true
+		case header && strings.HasPrefix(line, "@@"):
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parse reads the files and hunks from a unified diff
+//
+// It is lenient with the diffs models tend to write, hunk headers without line numbers (a bare @@)
+// are matched anywhere in the file and the line counts in headers are not trusted
+func Parse(text string) ([]File, error) {
+	var (
+		files []File
+		file  *File
+		hunk  *diff.Hunk
+		lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	)
+
+	endHunk := func() {
+		if hunk != nil && file != nil {
+			// blank lines after the diff would otherwise be read as empty context lines
+			for n := len(hunk.Edits); n > 0 && hunk.Edits[n-1] == (diff.Edit{Op: diff.Equal}); n-- {
+				hunk.Edits = hunk.Edits[:n-1]
+			}
+
+			countLines(hunk)
+			file.Hunks = append(file.Hunks, *hunk)
+		}
+		hunk = nil
+	}
+	endFile := func() {
+		endHunk()
+		if file != nil {
+			files = append(files, *file)
+		}
+		file = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			endFile()
+			file = &File{}
+			if names := strings.Fields(strings.TrimPrefix(line, "diff --git ")); len(names) == 2 {
+				file.OldName, file.NewName = trimName(names[0]), trimName(names[1])
+			}
+		case isFileHeader(lines, i, hunk != nil):
+			// a git header has already started the file
+			if file == nil || hunk != nil || len(file.Hunks) > 0 {
+				endFile()
+				file = &File{}
+			}
+
+			file.OldName = trimName(strings.TrimPrefix(line, "--- "))
+			file.NewName = trimName(strings.TrimPrefix(lines[i+1], "+++ "))
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if file == nil {
+				return nil, fmt.Errorf("line %d: hunk found before a file header", i+1)
+			}
+
+			endHunk()
+			hunk = parseHunkHeader(line)
+		case hunk != nil:
+			switch {
+			case strings.HasPrefix(line, "+"):
+				hunk.Edits = append(hunk.Edits, diff.Edit{Op: diff.Insert, Text: line[1:]})
+			case strings.HasPrefix(line, "-"):
+				hunk.Edits = append(hunk.Edits, diff.Edit{Op: diff.Delete, Text: line[1:]})
+			case strings.HasPrefix(line, " "):
+				hunk.Edits = append(hunk.Edits, diff.Edit{Op: diff.Equal, Text: line[1:]})
+			case line == "":
+				// editors and models often strip the space from empty context lines
+				hunk.Edits = append(hunk.Edits, diff.Edit{Op: diff.Equal})
+			case strings.HasPrefix(line, `\`):
+				// \ No newline at end of file
+			default:
+				endHunk()
+			}
+		}
+	}
+	endFile()
+
+	files = withHunks(files)
+	if len(files) == 0 {
+		return nil, ErrNoDiff
+	}
+
+	return files, nil
+}
+
+// isFileHeader reports if the line at i starts a --- +++ file header
+// inside a hunk it could also be a deleted line starting with -- followed by an added line starting
+// with ++, it is only taken as a header there if a hunk header follows it
+func isFileHeader(lines []string, i int, inHunk bool) bool {
+	if !strings.HasPrefix(lines[i], "--- ") || i+1 >= len(lines) || !strings.HasPrefix(lines[i+1], "+++ ") {
+		return false
+	}
+
+	return !inHunk || (i+2 < len(lines) && strings.HasPrefix(lines[i+2], "@@"))
+}
+
+// parseHunkHeader reads the line numbers from an @@ line, headers without line numbers leave
+// OldStart as 0 so the hunk is searched for in the whole file
+func parseHunkHeader(line string) *diff.Hunk {
+	hunk := &diff.Hunk{}
+
+	match := hunkHeader.FindStringSubmatch(line)
+	if match == nil {
+		return hunk
+	}
+
+	hunk.OldStart, _ = strconv.Atoi(match[1])
+	hunk.NewStart, _ = strconv.Atoi(match[3])
+	// an empty range refers to the line before, move it on so all hunks start on their first line
+	if match[2] == "0" {
+		hunk.OldStart++
+	}
+	if match[4] == "0" {
+		hunk.NewStart++
+	}
+
+	return hunk
+}
+
+// countLines sets the line counts of the hunk from its edits rather than trusting the header
+func countLines(hunk *diff.Hunk) {
+	hunk.OldLines, hunk.NewLines = 0, 0
+
+	for _, e := range hunk.Edits {
+		if e.Op != diff.Insert {
+			hunk.OldLines++
+		}
+		if e.Op != diff.Delete {
+			hunk.NewLines++
+		}
+	}
+}
+
+// trimName removes the a/ b/ prefix and any trailing timestamp from a file name
+func trimName(name string) string {
+	name, _, _ = strings.Cut(name, "\t")
+	name = strings.TrimSpace(name)
+	if name == devNull {
+		return ""
+	}
+
+	for _, prefix := range []string{"a/", "b/"} {
+		if strings.HasPrefix(name, prefix) {
+			return name[len(prefix):]
+		}
+	}
+
+	return name
+}
+
+// withHunks drops the files that have no hunks or no path
+func withHunks(files []File) []File {
+	kept := files[:0]
+
+	for _, file := range files {
+		if len(file.Hunks) > 0 && file.Path() != "" {
+			kept = append(kept, file)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,151 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/indeedhat/term-gpt/internal/diff"
+)
+
+// edits builds the edits of a hunk from lines written as they appear in a diff
+func edits(lines ...string) []diff.Edit {
+	ops := map[byte]diff.Op{' ': diff.Equal, '+': diff.Insert, '-': diff.Delete}
+
+	out := make([]diff.Edit, 0, len(lines))
+	for _, line := range lines {
+		out = append(out, diff.Edit{Op: ops[line[0]], Text: line[1:]})
+	}
+
+	return out
+}
+
+// hunk builds a hunk with its line counts worked out from the edits
+func hunk(oldStart, newStart int, lines ...string) diff.Hunk {
+	h := diff.Hunk{OldStart: oldStart, NewStart: newStart, Edits: edits(lines...)}
+	countLines(&h)
+
+	return h
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want []File
+		err  error
+	}{
+		{
+			name: "git diff",
+			text: "diff --git a/main.go b/main.go\nindex 1234567..89abcde 100644\n" +
+				"--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,3 @@\n package main\n-var a = 1\n+var a = 2\n \n" +
+				"@@ -10,2 +10,3 @@ func main() {\n \tfmt.Println(a)\n+\tfmt.Println(a)\n }\n",
+			want: []File{{OldName: "main.go", NewName: "main.go", Hunks: []diff.Hunk{
+				hunk(1, 1, " package main", "-var a = 1", "+var a = 2"),
+				hunk(10, 10, " \tfmt.Println(a)", "+\tfmt.Println(a)", " }"),
+			}}},
+		},
+		{
+			name: "several files without git headers",
+			text: "--- a.txt\t2024-01-01 00:00:00\n+++ a.txt\t2024-01-02 00:00:00\n@@ -1 +1 @@\n-a\n+b\n" +
+				"--- b.txt\n+++ b.txt\n@@ -2 +2 @@\n-c\n+d\n",
+			want: []File{
+				{OldName: "a.txt", NewName: "a.txt", Hunks: []diff.Hunk{hunk(1, 1, "-a", "+b")}},
+				{OldName: "b.txt", NewName: "b.txt", Hunks: []diff.Hunk{hunk(2, 2, "-c", "+d")}},
+			},
+		},
+		{
+			name: "bare hunk header",
+			text: "--- a/main.go\n+++ b/main.go\n@@\n func main() {\n-\treturn\n+\tos.Exit(1)\n }\n",
+			want: []File{{OldName: "main.go", NewName: "main.go", Hunks: []diff.Hunk{
+				hunk(0, 0, " func main() {", "-\treturn", "+\tos.Exit(1)", " }"),
+			}}},
+		},
+		{
+			name: "stripped blank context lines",
+			text: "--- a/a.txt\n+++ b/a.txt\n@@ -1,4 +1,4 @@\n one\n\n-two\n+three\n\n\n",
+			want: []File{{OldName: "a.txt", NewName: "a.txt", Hunks: []diff.Hunk{
+				hunk(1, 1, " one", " ", "-two", "+three"),
+			}}},
+		},
+		{
+			name: "deleted line starting with dashes",
+			text: "--- a/notes.md\n+++ b/notes.md\n@@ -1,3 +1,3 @@\n title\n--- old\n+++ new\n end\n",
+			want: []File{{OldName: "notes.md", NewName: "notes.md", Hunks: []diff.Hunk{
+				hunk(1, 1, " title", "--- old", "+++ new", " end"),
+			}}},
+		},
+		{
+			name: "file header inside a hunk",
+			text: "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+b\n--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-c\n+d\n",
+			want: []File{
+				{OldName: "a.txt", NewName: "a.txt", Hunks: []diff.Hunk{hunk(1, 1, "-a", "+b")}},
+				{OldName: "b.txt", NewName: "b.txt", Hunks: []diff.Hunk{hunk(1, 1, "-c", "+d")}},
+			},
+		},
+		{
+			name: "new file",
+			text: "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+one\n+two\n\\ No newline at end of file\n",
+			want: []File{{NewName: "new.txt", Hunks: []diff.Hunk{hunk(1, 1, "+one", "+two")}}},
+		},
+		{
+			name: "deleted file",
+			text: "diff --git a/old.txt b/old.txt\ndeleted file mode 100644\n--- a/old.txt\n+++ /dev/null\n" +
+				"@@ -1,2 +0,0 @@\n-one\n-two\n",
+			want: []File{{OldName: "old.txt", Hunks: []diff.Hunk{hunk(1, 1, "-one", "-two")}}},
+		},
+		{
+			name: "text around the diff",
+			text: "Here is the fix:\n--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+b\nThat should do it\n",
+			want: []File{{OldName: "a.txt", NewName: "a.txt", Hunks: []diff.Hunk{hunk(1, 1, "-a", "+b")}}},
+		},
+		{
+			name: "hunk before a file header",
+			text: "@@ -1 +1 @@\n-a\n+b\n",
+			err:  errors.New("line 1: hunk found before a file header"),
+		},
+		{
+			name: "no diff",
+			text: "just some text\n",
+			err:  ErrNoDiff,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Parse(c.text)
+			if c.err != nil {
+				if err == nil || err.Error() != c.err.Error() {
+					t.Fatalf("Parse() error = %v, want %v", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Parse() =\n%+v\nwant\n%+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLooks(t *testing.T) {
+	cases := []struct {
+		text string
+		want bool
+	}{
+		{"--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+b\n", true},
+		{"diff --git a/a.txt b/a.txt\n@@\n-a\n+b\n", true},
+		{"@@ -1 +1 @@\n-a\n+b\n", false},
+		{"- a list\n+ not a diff\n", false},
+	}
+
+	for _, c := range cases {
+		if got := Looks(c.text); got != c.want {
+			t.Errorf("Looks(%q) = %v, want %v", strings.SplitN(c.text, "\n", 2)[0], got, c.want)
+		}
+	}
+}
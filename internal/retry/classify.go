@@ -0,0 +1,163 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Kind is the category of a failed request, it decides if the request is worth retrying
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	// KindRateLimit is returned when too many requests have been sent in a short period
+	KindRateLimit
+	// KindQuota is returned when the account has run out of credit, retrying will not help
+	KindQuota
+	// KindServer covers 5xx errors from the API
+	KindServer
+	// KindNetwork covers connection failures and timeouts before a response was received
+	KindNetwork
+	// KindAuth is returned when the api key is missing, invalid or lacks permission
+	KindAuth
+	// KindContextLength is returned when the request has more tokens than the model allows
+	KindContextLength
+	// KindBadRequest covers all other 4xx errors
+	KindBadRequest
+	// KindCancelled is returned when the request was cancelled by the app closing
+	KindCancelled
+)
+
+// String implements fmt.Stringer.
+func (k Kind) String() string {
+	switch k {
+	case KindRateLimit:
+		return "rate limited"
+	case KindQuota:
+		return "quota exceeded"
+	case KindServer:
+		return "server error"
+	case KindNetwork:
+		return "network error"
+	case KindAuth:
+		return "authentication failed"
+	case KindContextLength:
+		return "context too long"
+	case KindBadRequest:
+		return "bad request"
+	case KindCancelled:
+		return "cancelled"
+	default:
+		return "request failed"
+	}
+}
+
+// Retryable reports if a request that failed with this kind of error may succeed if it is sent again
+func (k Kind) Retryable() bool {
+	return k == KindRateLimit || k == KindServer || k == KindNetwork
+}
+
+// Error is a classified request error
+type Error struct {
+	Kind Kind
+	Err  error
+	// RetryAfter is the delay requested by the server before trying again, 0 if none was given
+	RetryAfter time.Duration
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Kind, e.Err)
+
+	switch e.Kind {
+	case KindAuth:
+		msg += " (check your key with `term-gpt auth status`)"
+	case KindContextLength:
+		msg += " (try lowering max_prev_messages or starting a new chat)"
+	}
+
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Classify works out the kind of error returned from the openai client
+// nil is returned if err is nil, errors that have already been classified are returned as is
+func Classify(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified
+	}
+
+	return &Error{Kind: kindOf(err), Err: err}
+}
+
+// kindOf does the actual work for Classify
+func kindOf(err error) Kind {
+	if errors.Is(err, context.Canceled) {
+		return KindCancelled
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		if code, ok := apiErr.Code.(string); ok {
+			switch code {
+			case "context_length_exceeded":
+				return KindContextLength
+			case "insufficient_quota":
+				return KindQuota
+			case "invalid_api_key":
+				return KindAuth
+			}
+		}
+
+		return kindOfStatus(apiErr.HTTPStatusCode)
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return kindOfStatus(reqErr.HTTPStatusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return KindNetwork
+	}
+
+	return KindUnknown
+}
+
+// kindOfStatus classifies an error by its http status code
+func kindOfStatus(status int) Kind {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return KindRateLimit
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return KindAuth
+	case status == http.StatusRequestTimeout:
+		return KindNetwork
+	case status >= 500:
+		return KindServer
+	case status >= 400:
+		return KindBadRequest
+	default:
+		return KindUnknown
+	}
+}
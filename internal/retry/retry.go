@@ -0,0 +1,45 @@
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	// baseDelay is the delay before the first retry, it doubles with each attempt
+	baseDelay = time.Second
+	// maxDelay caps the backoff delay, a longer Retry-After from the server is still honoured
+	maxDelay = 30 * time.Second
+)
+
+// Backoff returns how long to wait before making the given retry attempt (starting at 1)
+//
+// The delay grows exponentially with random jitter so that clients hitting the same rate limit do
+// not all retry at once, if the server asked for a longer delay then that is used instead
+func Backoff(attempt int, retryAfter time.Duration) time.Duration {
+	ceiling := baseDelay << (attempt - 1)
+	if ceiling > maxDelay || ceiling <= 0 {
+		ceiling = maxDelay
+	}
+
+	delay := ceiling/2 + time.Duration(rand.Int63n(int64(ceiling/2)+1))
+	if retryAfter > delay {
+		return retryAfter
+	}
+
+	return delay
+}
+
+// Sleep waits for the delay to pass, it returns early with false if the context is cancelled
+func Sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,90 @@
+package retry
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+type hintKey struct{}
+
+// Hint collects the Retry-After header from the responses to requests made with its context
+//
+// The openai client does not expose response headers on its errors so Transport records them
+// here instead
+type Hint struct {
+	retryAfter atomic.Int64
+}
+
+// RetryAfter returns the delay requested by the last response, 0 if none was given
+func (h *Hint) RetryAfter() time.Duration {
+	return time.Duration(h.retryAfter.Load())
+}
+
+// WithHint returns a copy of ctx that will collect the Retry-After header from requests made with it
+func WithHint(ctx context.Context) (context.Context, *Hint) {
+	hint := &Hint{}
+	return context.WithValue(ctx, hintKey{}, hint), hint
+}
+
+// Transport is a http.RoundTripper that records the Retry-After header of failed responses in the
+// Hint attached to the request context
+type Transport struct {
+	// Base is the transport used to make the requests, http.DefaultTransport is used if nil
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if hint, ok := req.Context().Value(hintKey{}).(*Hint); ok {
+		hint.retryAfter.Store(int64(parseRetryAfter(resp.Header, time.Now())))
+	}
+
+	return resp, nil
+}
+
+// NewHTTPClient creates a http client that records Retry-After headers
+func NewHTTPClient() *http.Client {
+	return &http.Client{Transport: &Transport{}}
+}
+
+// parseRetryAfter reads the delay from the Retry-After header which can be either a number of
+// seconds or a http date, the non standard retry-after-ms header sent by openai is preferred as it
+// is more precise
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+
+	return 0
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
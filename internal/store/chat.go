@@ -5,13 +5,69 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/sashabaranov/go-openai"
 )
 
-type ChatLog []openai.ChatCompletionMessage
+// Message is a single entry in the chat log
+//
+// The json keys for role and content match those of openai.ChatCompletionMessage so that chat
+// logs saved before the introduction of this type can still be loaded
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Attachments contains any local files that were sent along with the message
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// Images contains references to any local images that were sent along with the message
+	Images []Image `json:"images,omitempty"`
+	// ToolCalls holds the tools that the model asked to call when it generated this message
+	ToolCalls []openai.ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID links a tool result message to the call that produced it
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// Name is the name of the tool that produced a tool result message
+	Name string `json:"name,omitempty"`
+	// Usage holds the tokens used to generate the message, it is only set on model replies
+	Usage *Usage `json:"usage,omitempty"`
+	// Status marks entries that are only for display and are never sent to the model
+	Status string `json:"status,omitempty"`
+}
+
+const (
+	// StatusNotice marks a message from term-gpt itself rather than the user or model
+	StatusNotice = "notice"
+	// StatusError marks a request that failed, the error is stored in place of the reply
+	StatusError = "error"
+	// StatusFailed marks the prompt and any tool calls from a request that failed
+	StatusFailed = "failed"
+)
+
+// Attachment is a local file that has been attached to a message
+type Attachment struct {
+	// Path is the path to the file as it was given by the user
+	Path string `json:"path"`
+	// Language is the language hint used when wrapping the file in a fenced code block
+	Language string `json:"language"`
+	// Content is the content of the file at the time it was attached
+	Content string `json:"content"`
+}
+
+// Image is a reference to a local image file that has been attached to a message
+//
+// Only the path is stored, the image itself is read from disk whenever it needs to be sent
+type Image struct {
+	Path     string `json:"path"`
+	MimeType string `json:"mime_type"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+type ChatLog []Message
 
 // Value implements driver.Valuer.
 func (l ChatLog) Value() (driver.Value, error) {
@@ -42,16 +98,111 @@ func (l *ChatLog) Scan(src any) error {
 var _ driver.Valuer = (*ChatLog)(nil)
 var _ sql.Scanner = (*ChatLog)(nil)
 
+// maxTagLength is the longest tag that can be stored, longer tags are cut down
+const maxTagLength = 32
+
+// Tags are the labels used to group chats, they are kept normalised (see NormalizeTag), sorted and
+// without duplicates
+type Tags []string
+
+// NormalizeTag cleans up a tag as it was typed by the user, the leading # is optional and tags are
+// case insensitive. An empty string is returned if there is nothing left of the tag
+func NormalizeTag(tag string) string {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+
+	return strings.ToLower(substr(tag, 0, maxTagLength))
+}
+
+// Add returns a copy of the tags with the given tags added
+func (t Tags) Add(tags ...string) Tags {
+	out := slices.Clone(t)
+	for _, tag := range tags {
+		if tag = NormalizeTag(tag); tag != "" && !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+
+	slices.Sort(out)
+	return out
+}
+
+// Remove returns a copy of the tags without the given tags
+func (t Tags) Remove(tags ...string) Tags {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		normalized = append(normalized, NormalizeTag(tag))
+	}
+
+	return slices.DeleteFunc(slices.Clone(t), func(tag string) bool {
+		return slices.Contains(normalized, tag)
+	})
+}
+
+// String formats the tags as they are shown in the ui, #go #work
+func (t Tags) String() string {
+	if len(t) == 0 {
+		return ""
+	}
+
+	return "#" + strings.Join(t, " #")
+}
+
+// Value implements driver.Valuer.
+func (t Tags) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner.
+// chats saved before tags were added have a NULL tags column
+func (t *Tags) Scan(src any) error {
+	switch val := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(val, t)
+	case string:
+		return json.Unmarshal([]byte(val), t)
+	default:
+		return errors.New("invalid type")
+	}
+}
+
+var _ driver.Valuer = (*Tags)(nil)
+var _ sql.Scanner = (*Tags)(nil)
+
 type ChatHistory struct {
 	ChatHistoryMeta
 
 	ChatLog ChatLog
+	// Settings holds the per chat overrides for the default sampling parameters
+	Settings Sampling
 }
 
 type ChatHistoryMeta struct {
 	Id        int
 	ChatTitle string
 	UpdatedAt time.Time
+
+	// Pinned chats are listed above all others in the chat history
+	Pinned bool
+	// Starred marks a chat as a favourite, the history can be filtered down to just the starred chats
+	Starred bool
+	Tags    Tags
+
+	// Pending is set while the chat is waiting on a reply, it is not stored in the database
+	Pending bool
+	// Unread is set when a reply arrives while the chat is not active, it is not stored in the database
+	Unread bool
 }
 
 // FilterValue implements list.Item.
@@ -59,19 +210,55 @@ func (m ChatHistoryMeta) FilterValue() string {
 	return m.ChatTitle
 }
 
-// Title returns the private title member
+// Title returns the private title member prefixed with the pending/unread and starred badges
 func (m ChatHistoryMeta) Title() string {
-	return m.ChatTitle
+	title := m.ChatTitle
+	if m.Starred {
+		title = "★ " + title
+	}
+
+	switch {
+	case m.Pending:
+		return "⋯ " + title
+	case m.Unread:
+		return "● " + title
+	default:
+		return title
+	}
 }
 
-// Description returns the private date member as the list item description
+// Description returns the private date member followed by any tags as the list item description
 func (m ChatHistoryMeta) Description() string {
-	return m.UpdatedAt.Format(time.DateTime)
+	if len(m.Tags) == 0 {
+		return m.UpdatedAt.Format(time.DateTime)
+	}
+
+	return m.UpdatedAt.Format(time.DateTime) + " " + m.Tags.String()
 }
 
 // Ensure that ChatHistoryMeta can be used as a list item by bubbletea
 var _ list.Item = (*ChatHistoryMeta)(nil)
 
+// ListOptions controls which chats are returned by ChatHistoryRepo.List
+type ListOptions struct {
+	// Offset is the number of chats to skip
+	Offset int
+	// Limit is the max number of chats to return, 0 == no limit
+	Limit int
+	// Filter only returns the chats with a title containing the filter text
+	Filter string
+	// Tag only returns the chats with the tag
+	Tag string
+	// Starred only returns the starred chats
+	Starred bool
+}
+
+// TagCount is the number of chats using a tag
+type TagCount struct {
+	Tag   string
+	Chats int
+}
+
 type ChatHistoryRepo interface {
 	// MigrateSchema creates/updates the database schema for the chat_history table
 	MigrateSchema() error
@@ -79,9 +266,15 @@ type ChatHistoryRepo interface {
 	Create(entry *ChatHistory) error
 	// Update updates an existing entry in the chat_history table
 	Update(entry *ChatHistory) error
-	// List returns a list of all the saved chat logs in the chat_history table
+	// UpdateMeta stores the pinned/starred flags and tags of an existing entry in the chat_history
+	// table, the updated time is left alone so the chat keeps its place in the history
+	UpdateMeta(entry *ChatHistoryMeta) error
+	// List returns a page of the saved chat logs in the chat_history table, pinned chats first then
+	// the most recently updated
 	// It will only return the meta data for each entry, not the chat logs themselves
-	List() []ChatHistoryMeta
+	List(opts ListOptions) []ChatHistoryMeta
+	// Tags returns every tag in use along with the number of chats using it, sorted by tag
+	Tags() []TagCount
 	// Fild returns a full entry from the chat_history table with the logs included
 	Find(id int) *ChatHistory
 }
@@ -106,6 +299,36 @@ func (r ChatHistorySqliteRepo) MigrateSchema() error {
             chat_log TEXT
         )
     `)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`
+        CREATE INDEX IF NOT EXISTS chat_history_updated_at ON chat_history (updated_at)
+    `)
+	if err != nil {
+		return err
+	}
+
+	columns := []struct {
+		name       string
+		definition string
+	}{
+		{"settings", "TEXT"},
+		{"pinned", "INTEGER NOT NULL DEFAULT 0"},
+		{"starred", "INTEGER NOT NULL DEFAULT 0"},
+		{"tags", "TEXT"},
+	}
+	for _, col := range columns {
+		if err := addColumn(r.db, "chat_history", col.name, col.definition); err != nil {
+			return err
+		}
+	}
+
+	// pinned chats are listed first
+	_, err = r.db.Exec(`
+        CREATE INDEX IF NOT EXISTS chat_history_pinned ON chat_history (pinned, updated_at)
+    `)
 
 	return err
 }
@@ -116,18 +339,28 @@ func (r ChatHistorySqliteRepo) Create(entry *ChatHistory) error {
 		return errors.New("cannot save an empty chat log")
 	}
 
-	title := substr(entry.ChatLog[0].Content, 0, 100)
+	first := entry.ChatLog[0]
+	title := substr(first.Content, 0, 100)
+	if title == "" && len(first.Attachments) > 0 {
+		title = substr(first.Attachments[0].Path, 0, 100)
+	} else if title == "" && len(first.Images) > 0 {
+		title = substr(filepath.Base(first.Images[0].Path), 0, 100)
+	}
 	entry.ChatTitle = title
 
 	res, err := r.db.Exec(`
         INSERT INTO chat_history (
             title,
             updated_at,
-            chat_log
+            chat_log,
+            settings,
+            pinned,
+            starred,
+            tags
         ) VALUES (
-            ?, strftime('%s', 'now'), ?
+            ?, strftime('%s', 'now'), ?, ?, ?, ?, ?
         )
-    `, title, entry.ChatLog)
+    `, title, entry.ChatLog, entry.Settings, entry.Pinned, entry.Starred, entry.Tags)
 	if err != nil {
 		return err
 	}
@@ -150,7 +383,7 @@ func (r ChatHistorySqliteRepo) Find(id int) *ChatHistory {
 	var entry ChatHistory
 
 	row := r.db.QueryRow(`
-        SELECT id, title, updated_at, chat_log
+        SELECT id, title, updated_at, chat_log, settings, pinned, starred, tags
         FROM chat_history
         WHERE id = ?
     `, id)
@@ -159,7 +392,16 @@ func (r ChatHistorySqliteRepo) Find(id int) *ChatHistory {
 	}
 	var ud int64
 
-	err := row.Scan(&entry.Id, &entry.ChatTitle, &ud, &entry.ChatLog)
+	err := row.Scan(
+		&entry.Id,
+		&entry.ChatTitle,
+		&ud,
+		&entry.ChatLog,
+		&entry.Settings,
+		&entry.Pinned,
+		&entry.Starred,
+		&entry.Tags,
+	)
 	if err != nil {
 		return nil
 	}
@@ -169,24 +411,40 @@ func (r ChatHistorySqliteRepo) Find(id int) *ChatHistory {
 }
 
 // List implements ChatHistoryRepo.
-func (r ChatHistorySqliteRepo) List() []ChatHistoryMeta {
+func (r ChatHistorySqliteRepo) List(opts ListOptions) []ChatHistoryMeta {
 	var entries []ChatHistoryMeta
 
+	// a negative limit means no limit in sqlite
+	limit := opts.Limit
+	if limit == 0 {
+		limit = -1
+	}
+
 	rows, err := r.db.Query(`
-        SELECT id, title, updated_at
+        SELECT id, title, updated_at, pinned, starred, tags
         FROM chat_history
-        ORDER BY updated_at DESC
-    `)
+        WHERE (? = '' OR title LIKE ? ESCAPE '\')
+            AND (? = '' OR EXISTS (SELECT 1 FROM json_each(chat_history.tags) WHERE value = ?))
+            AND (NOT ? OR starred)
+        ORDER BY pinned DESC, updated_at DESC, id DESC
+        LIMIT ? OFFSET ?
+    `,
+		opts.Filter, "%"+escapeLike(opts.Filter)+"%",
+		opts.Tag, opts.Tag,
+		opts.Starred,
+		limit, opts.Offset,
+	)
 	if err != nil {
 		return nil
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var (
 			entry ChatHistoryMeta
 			ud    int64
 		)
-		if err := rows.Scan(&entry.Id, &entry.ChatTitle, &ud); err != nil {
+		if err := rows.Scan(&entry.Id, &entry.ChatTitle, &ud, &entry.Pinned, &entry.Starred, &entry.Tags); err != nil {
 			continue
 		}
 
@@ -199,14 +457,67 @@ func (r ChatHistorySqliteRepo) List() []ChatHistoryMeta {
 
 // Update implements ChatHistoryRepo.
 func (r ChatHistorySqliteRepo) Update(entry *ChatHistory) error {
+	now := time.Now().Unix()
+
+	_, err := r.db.Exec(`
+        UPDATE chat_history
+        SET updated_at = ?,
+            chat_log = ?,
+            settings = ?
+        WHERE id = ?
+    `, strconv.FormatInt(now, 10), entry.ChatLog, entry.Settings, entry.Id)
+	if err != nil {
+		return err
+	}
+
+	entry.UpdatedAt = time.Unix(now, 0)
+
+	return nil
+}
+
+// UpdateMeta implements ChatHistoryRepo.
+func (r ChatHistorySqliteRepo) UpdateMeta(entry *ChatHistoryMeta) error {
 	_, err := r.db.Exec(`
         UPDATE chat_history
-        SET updated_at =  strftime('%s', 'now'),
-            chat_log = ?
+        SET pinned = ?,
+            starred = ?,
+            tags = ?
         WHERE id = ?
-    `, entry.ChatLog, entry.Id)
+    `, entry.Pinned, entry.Starred, entry.Tags, entry.Id)
 
 	return err
 }
 
+// Tags implements ChatHistoryRepo.
+func (r ChatHistorySqliteRepo) Tags() []TagCount {
+	var tags []TagCount
+
+	rows, err := r.db.Query(`
+        SELECT tag.value, COUNT(*)
+        FROM chat_history, json_each(chat_history.tags) AS tag
+        GROUP BY tag.value
+        ORDER BY tag.value
+    `)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Chats); err != nil {
+			continue
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// escapeLike escapes the wildcards in a LIKE pattern
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+}
+
 var _ ChatHistoryRepo = (*ChatHistorySqliteRepo)(nil)
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// Sampling holds the sampling parameters sent along with completion requests
+//
+// Nil values are unset and will fall back to the next level of defaults (chat -> config -> API)
+type Sampling struct {
+	Temperature      *float32 `json:"temperature,omitempty" toml:"temperature"`
+	TopP             *float32 `json:"top_p,omitempty" toml:"top_p"`
+	PresencePenalty  *float32 `json:"presence_penalty,omitempty" toml:"presence_penalty"`
+	FrequencyPenalty *float32 `json:"frequency_penalty,omitempty" toml:"frequency_penalty"`
+	Stop             []string `json:"stop,omitempty" toml:"stop"`
+	Seed             *int     `json:"seed,omitempty" toml:"seed"`
+}
+
+// Merge returns a copy of s with any values set in override applied over the top
+func (s Sampling) Merge(override Sampling) Sampling {
+	if override.Temperature != nil {
+		s.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		s.TopP = override.TopP
+	}
+	if override.PresencePenalty != nil {
+		s.PresencePenalty = override.PresencePenalty
+	}
+	if override.FrequencyPenalty != nil {
+		s.FrequencyPenalty = override.FrequencyPenalty
+	}
+	if override.Stop != nil {
+		s.Stop = override.Stop
+	}
+	if override.Seed != nil {
+		s.Seed = override.Seed
+	}
+
+	return s
+}
+
+// Validate checks the parameters are within the ranges accepted by the openai API
+func (s Sampling) Validate() error {
+	var errs []error
+
+	if s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > 2) {
+		errs = append(errs, errors.New("temperature must be between 0 and 2"))
+	}
+	if s.TopP != nil && (*s.TopP < 0 || *s.TopP > 1) {
+		errs = append(errs, errors.New("top_p must be between 0 and 1"))
+	}
+	if s.PresencePenalty != nil && (*s.PresencePenalty < -2 || *s.PresencePenalty > 2) {
+		errs = append(errs, errors.New("presence_penalty must be between -2 and 2"))
+	}
+	if s.FrequencyPenalty != nil && (*s.FrequencyPenalty < -2 || *s.FrequencyPenalty > 2) {
+		errs = append(errs, errors.New("frequency_penalty must be between -2 and 2"))
+	}
+	if len(s.Stop) > 4 {
+		errs = append(errs, errors.New("no more than 4 stop sequences can be used"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Value implements driver.Valuer.
+func (s Sampling) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner.
+func (s *Sampling) Scan(src any) error {
+	switch val := src.(type) {
+	case nil:
+		*s = Sampling{}
+		return nil
+	case []byte:
+		return json.Unmarshal(val, s)
+	case string:
+		return json.Unmarshal([]byte(val), s)
+	default:
+		return errors.New("invalid type")
+	}
+}
+
+var _ driver.Valuer = (*Sampling)(nil)
+var _ sql.Scanner = (*Sampling)(nil)
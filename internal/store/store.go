@@ -2,20 +2,57 @@ package store
 
 import (
 	"database/sql"
+	"fmt"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// Connect to the sqlite database
+// Connect to the sqlite database at path
 // This will create the database file if it does not exist
-func Connect() (*sql.DB, error) {
-	return sql.Open("sqlite3", "chatLog.db")
+func Connect(path string) (*sql.DB, error) {
+	return sql.Open("sqlite3", path)
 }
 
 // AutoMigrate will run the migration method on sqlite repos in turn
 func AutoMigrate(db *sql.DB) error {
-	r := NewChatHistorySqliteRepo(db)
-	return r.MigrateSchema()
+	if err := NewChatHistorySqliteRepo(db).MigrateSchema(); err != nil {
+		return err
+	}
+
+	return NewUsageSqliteRepo(db).MigrateSchema()
+}
+
+// addColumn adds a column to an existing table if it does not already exist
+// this allows new columns to be added to databases created by older versions of the app
+func addColumn(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     any
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+
+		if name == column {
+			return nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+
+	return err
 }
 
 // substr is a utf8 safe substring extractor function that respects string length
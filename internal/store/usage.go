@@ -0,0 +1,192 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Usage records the tokens used to generate a message
+type Usage struct {
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	// Cost is the cost in USD calculated with the pricing table at the time of the request
+	Cost float64 `json:"cost"`
+}
+
+// TotalTokens returns the combined prompt and completion token count
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// UsageEntry is a single request recorded in the usage_log table
+type UsageEntry struct {
+	Usage
+
+	ChatId    int
+	CreatedAt time.Time
+}
+
+// UsageSummary is the combined usage for a group of requests
+type UsageSummary struct {
+	// Key is the value the requests were grouped by (day, model or chat title)
+	Key              string
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+}
+
+type UsageRepo interface {
+	// MigrateSchema creates/updates the database schema for the usage_log table
+	MigrateSchema() error
+	// Record adds a new entry to the usage_log table
+	Record(entry *UsageEntry) error
+	// SpendSince returns the total cost of all requests made since the given time
+	SpendSince(since time.Time) float64
+	// ByDay summarises the usage since the given time grouped by day
+	ByDay(since time.Time) []UsageSummary
+	// ByModel summarises the usage since the given time grouped by model
+	ByModel(since time.Time) []UsageSummary
+	// ByChat summarises the usage since the given time grouped by chat
+	ByChat(since time.Time) []UsageSummary
+}
+
+type UsageSqliteRepo struct {
+	db *sql.DB
+}
+
+// NewUsageSqliteRepo sets up the sqlite repository for managing the usage data store
+func NewUsageSqliteRepo(db *sql.DB) UsageSqliteRepo {
+	return UsageSqliteRepo{db}
+}
+
+// MigrateSchema implements UsageRepo.
+func (r UsageSqliteRepo) MigrateSchema() error {
+	_, err := r.db.Exec(`
+        CREATE TABLE IF NOT EXISTS usage_log (
+            id INTEGER PRIMARY key AUTOINCREMENT,
+            chat_id INTEGER,
+            model varchar(100),
+            prompt_tokens INTEGER,
+            completion_tokens INTEGER,
+            cost REAL,
+            created_at INTEGER
+        )
+    `)
+
+	return err
+}
+
+// Record implements UsageRepo.
+func (r UsageSqliteRepo) Record(entry *UsageEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	_, err := r.db.Exec(`
+        INSERT INTO usage_log (
+            chat_id,
+            model,
+            prompt_tokens,
+            completion_tokens,
+            cost,
+            created_at
+        ) VALUES (
+            ?, ?, ?, ?, ?, ?
+        )
+    `,
+		entry.ChatId,
+		entry.Model,
+		entry.PromptTokens,
+		entry.CompletionTokens,
+		entry.Cost,
+		entry.CreatedAt.Unix(),
+	)
+
+	return err
+}
+
+// SpendSince implements UsageRepo.
+func (r UsageSqliteRepo) SpendSince(since time.Time) float64 {
+	var spend float64
+
+	row := r.db.QueryRow(`
+        SELECT COALESCE(SUM(cost), 0)
+        FROM usage_log
+        WHERE created_at >= ?
+    `, since.Unix())
+	if err := row.Scan(&spend); err != nil {
+		return 0
+	}
+
+	return spend
+}
+
+// ByDay implements UsageRepo.
+func (r UsageSqliteRepo) ByDay(since time.Time) []UsageSummary {
+	return r.summarise(`
+        SELECT date(created_at, 'unixepoch', 'localtime') AS day,
+            COUNT(*), SUM(prompt_tokens), SUM(completion_tokens), SUM(cost)
+        FROM usage_log
+        WHERE created_at >= ?
+        GROUP BY day
+        ORDER BY day DESC
+    `, since)
+}
+
+// ByModel implements UsageRepo.
+func (r UsageSqliteRepo) ByModel(since time.Time) []UsageSummary {
+	return r.summarise(`
+        SELECT model,
+            COUNT(*), SUM(prompt_tokens), SUM(completion_tokens), SUM(cost)
+        FROM usage_log
+        WHERE created_at >= ?
+        GROUP BY model
+        ORDER BY SUM(cost) DESC
+    `, since)
+}
+
+// ByChat implements UsageRepo.
+func (r UsageSqliteRepo) ByChat(since time.Time) []UsageSummary {
+	return r.summarise(`
+        SELECT COALESCE(chat_history.title, 'deleted chat #' || usage_log.chat_id),
+            COUNT(*), SUM(prompt_tokens), SUM(completion_tokens), SUM(cost)
+        FROM usage_log
+        LEFT JOIN chat_history ON chat_history.id = usage_log.chat_id
+        WHERE created_at >= ?
+        GROUP BY usage_log.chat_id
+        ORDER BY SUM(cost) DESC
+    `, since)
+}
+
+// summarise runs one of the grouped summary queries
+func (r UsageSqliteRepo) summarise(query string, since time.Time) []UsageSummary {
+	var summaries []UsageSummary
+
+	rows, err := r.db.Query(query, since.Unix())
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var summary UsageSummary
+		err := rows.Scan(
+			&summary.Key,
+			&summary.Requests,
+			&summary.PromptTokens,
+			&summary.CompletionTokens,
+			&summary.Cost,
+		)
+		if err != nil {
+			continue
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries
+}
+
+var _ UsageRepo = (*UsageSqliteRepo)(nil)
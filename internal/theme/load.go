@@ -0,0 +1,156 @@
+package theme
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/glamour"
+)
+
+// All returns the built in themes followed by the user themes found in dir
+//
+// A user theme with the same name as a built in one replaces it. Each user theme is filled in from
+// its base theme, themes without a base build on the default theme
+func All(dir string) ([]Theme, error) {
+	user, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	themes := Builtin()
+	for _, t := range user {
+		base := Default()
+		if t.Base != "" {
+			var ok bool
+			if base, ok = Find(themes, t.Base); !ok {
+				return nil, fmt.Errorf("theme %s: unknown base theme %q", t.Name, t.Base)
+			}
+		}
+
+		t = t.inherit(base)
+		if i := index(themes, t.Name); i >= 0 {
+			themes[i] = t
+		} else {
+			themes = append(themes, t)
+		}
+	}
+
+	return themes, nil
+}
+
+// Load reads the user themes from the toml files in dir, sorted so that themes can use any theme
+// before them as a base
+//
+// The name of a theme defaults to the name of its file. A missing dir is not an error, there are
+// just no user themes
+func Load(dir string) ([]Theme, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var themes []Theme
+	for _, path := range paths {
+		t, err := loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		themes = append(themes, t)
+	}
+
+	return sortByBase(themes)
+}
+
+// loadFile decodes a single theme file
+func loadFile(path string) (Theme, error) {
+	var t Theme
+
+	meta, err := toml.DecodeFile(path, &t)
+	if err != nil {
+		return t, fmt.Errorf("invalid theme file %s: %w", path, err)
+	}
+
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, 0, len(undecoded))
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+
+		return t, fmt.Errorf("unknown keys in theme file %s: %s", path, strings.Join(keys, ", "))
+	}
+
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	// glamour style files are found relative to the theme file
+	if t.Glamour != "" && !isGlamourStyle(t.Glamour) && !filepath.IsAbs(t.Glamour) {
+		t.Glamour = filepath.Join(filepath.Dir(path), t.Glamour)
+	}
+	if t.Glamour != "" && !isGlamourStyle(t.Glamour) {
+		if _, err := os.Stat(t.Glamour); err != nil {
+			return t, fmt.Errorf("theme file %s: glamour style: %w", path, err)
+		}
+	}
+
+	return t, nil
+}
+
+// sortByBase orders the themes so that each comes after the user theme it is based on
+func sortByBase(themes []Theme) ([]Theme, error) {
+	var (
+		sorted  = make([]Theme, 0, len(themes))
+		pending = themes
+	)
+
+	for len(pending) > 0 {
+		var next []Theme
+		for _, t := range pending {
+			if t.Base != "" && t.Base != t.Name && index(pending, t.Base) >= 0 {
+				next = append(next, t)
+				continue
+			}
+
+			sorted = append(sorted, t)
+		}
+
+		if len(next) == len(pending) {
+			return nil, errors.New("theme files have a cycle in their base themes")
+		}
+		pending = next
+	}
+
+	return sorted, nil
+}
+
+// index returns the position of the named theme or -1 if it is not in the list
+func index(themes []Theme, name string) int {
+	for i, t := range themes {
+		if t.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// isGlamourStyle reports if the name is one of glamour's built in styles
+func isGlamourStyle(name string) bool {
+	if name == "auto" {
+		return true
+	}
+
+	_, ok := glamour.DefaultStyles[name]
+	return ok
+}
@@ -0,0 +1,250 @@
+package theme
+
+import (
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	NameAuto         = "auto"
+	NameDark         = "dark"
+	NameLight        = "light"
+	NameHighContrast = "high-contrast"
+)
+
+// Palette holds the colours of a theme
+//
+// Colours are either ANSI colour numbers ("5") or hex codes ("#af87ff"), an empty colour leaves the
+// terminal default in place
+type Palette struct {
+	// Accent highlights the focused pane, the cursor in lists and the name of each message
+	Accent string `toml:"accent"`
+	// AccentText is the colour of text drawn on top of the accent colour, such as the list title
+	AccentText string `toml:"accent_text"`
+	// Muted is used for secondary text such as the status line and attachments, when empty the
+	// text is drawn faint instead
+	Muted string `toml:"muted"`
+	// Border is the colour of the borders around panes that are not focused
+	Border  string `toml:"border"`
+	Success string `toml:"success"`
+	Warning string `toml:"warning"`
+	Error   string `toml:"error"`
+	Info    string `toml:"info"`
+}
+
+// Theme is a named set of colours along with the glamour style used to render markdown
+type Theme struct {
+	Name string `toml:"name"`
+	// Base is the theme that any settings left out of this one are taken from
+	Base string `toml:"base"`
+	// Glamour is the name of a built in glamour style (auto, dark, light, dracula, pink, notty or
+	// ascii) or the path to a glamour json style file
+	Glamour string  `toml:"glamour"`
+	Palette Palette `toml:"palette"`
+}
+
+// builtins are the themes that are always available, auto is the default and matches the
+// terminal's own colours
+var builtins = []Theme{
+	{
+		Name:    NameAuto,
+		Glamour: "auto",
+		Palette: Palette{
+			Accent:     "5",
+			AccentText: "230",
+			Success:    "2",
+			Warning:    "3",
+			Error:      "1",
+			Info:       "6",
+		},
+	},
+	{
+		Name:    NameDark,
+		Glamour: "dark",
+		Palette: Palette{
+			Accent:     "#af87ff",
+			AccentText: "#1c1c1c",
+			Muted:      "#808080",
+			Border:     "#585858",
+			Success:    "#87d787",
+			Warning:    "#ffd75f",
+			Error:      "#ff5f5f",
+			Info:       "#5fd7ff",
+		},
+	},
+	{
+		Name:    NameLight,
+		Glamour: "light",
+		Palette: Palette{
+			Accent:     "#8700af",
+			AccentText: "#ffffff",
+			Muted:      "#6c6c6c",
+			Border:     "#a8a8a8",
+			Success:    "#008700",
+			Warning:    "#af5f00",
+			Error:      "#d70000",
+			Info:       "#005f87",
+		},
+	},
+	{
+		Name:    NameHighContrast,
+		Glamour: "dark",
+		Palette: Palette{
+			Accent:     "11",
+			AccentText: "0",
+			Muted:      "15",
+			Border:     "15",
+			Success:    "10",
+			Warning:    "11",
+			Error:      "9",
+			Info:       "14",
+		},
+	},
+}
+
+// Default returns the theme used when none is configured
+func Default() Theme {
+	return builtins[0]
+}
+
+// Builtin returns a copy of the built in themes
+func Builtin() []Theme {
+	themes := make([]Theme, len(builtins))
+	copy(themes, builtins)
+
+	return themes
+}
+
+// Find looks up a theme by name
+func Find(themes []Theme, name string) (Theme, bool) {
+	for _, t := range themes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+
+	return Theme{}, false
+}
+
+// Markdown returns the glamour option that applies the theme's markdown style
+func (t Theme) Markdown() glamour.TermRendererOption {
+	return glamour.WithStylePath(t.Glamour)
+}
+
+// Styles holds every lipgloss style used by the ui, built from the palette of a theme
+type Styles struct {
+	// AccentColor and BorderColor are used by the components that take a colour rather than a style
+	AccentColor lipgloss.TerminalColor
+	BorderColor lipgloss.TerminalColor
+
+	// Accent is used for the cursor in pickers and forms and the spinner
+	Accent lipgloss.Style
+	// Title is the title of the chat history list
+	Title lipgloss.Style
+	// Group is the heading above each group of chats in the chat history
+	Group lipgloss.Style
+	// Status is the status line at the top of the screen
+	Status lipgloss.Style
+
+	// Name, Tool, Attachment, Notice and Error are used for the parts of each chat message
+	Name       lipgloss.Style
+	Tool       lipgloss.Style
+	Attachment lipgloss.Style
+	Notice     lipgloss.Style
+	Error      lipgloss.Style
+	// Selected marks the selected message in selection mode
+	Selected lipgloss.Style
+
+	DiffAdd    lipgloss.Style
+	DiffDelete lipgloss.Style
+	DiffHunk   lipgloss.Style
+	DiffFile   lipgloss.Style
+
+	// Ok, Warn and Fail mark the outcome of patches and the state of MCP servers
+	Ok   lipgloss.Style
+	Warn lipgloss.Style
+	Fail lipgloss.Style
+}
+
+// Styles builds the lipgloss styles for the theme
+func (t Theme) Styles() Styles {
+	p := t.Palette
+	fg := func(c string) lipgloss.Style {
+		return lipgloss.NewStyle().Foreground(color(c))
+	}
+
+	muted := fg(p.Muted)
+	if p.Muted == "" {
+		muted = muted.Faint(true)
+	}
+
+	return Styles{
+		AccentColor: color(p.Accent),
+		BorderColor: color(p.Border),
+
+		Accent: fg(p.Accent),
+		Title: lipgloss.NewStyle().
+			Background(color(p.Accent)).
+			Foreground(color(p.AccentText)).
+			Padding(0, 1),
+		Group:  fg(p.Accent).Bold(true),
+		Status: muted.Copy(),
+
+		Name:       fg(p.Accent),
+		Tool:       fg(p.Warning),
+		Attachment: muted.Copy(),
+		Notice:     fg(p.Info),
+		Error:      fg(p.Error),
+		Selected: lipgloss.NewStyle().
+			BorderStyle(lipgloss.ThickBorder()).
+			BorderLeft(true).
+			BorderForeground(color(p.Accent)).
+			PaddingLeft(1),
+
+		DiffAdd:    fg(p.Success),
+		DiffDelete: fg(p.Error),
+		DiffHunk:   fg(p.Info),
+		DiffFile:   lipgloss.NewStyle().Bold(true),
+
+		Ok:   fg(p.Success),
+		Warn: fg(p.Warning),
+		Fail: fg(p.Error),
+	}
+}
+
+// color converts a palette colour to a lipgloss colour, empty colours use the terminal default
+func color(c string) lipgloss.TerminalColor {
+	if c == "" {
+		return lipgloss.NoColor{}
+	}
+
+	return lipgloss.Color(c)
+}
+
+// inherit fills in any settings missing from t with those from base
+func (t Theme) inherit(base Theme) Theme {
+	if t.Glamour == "" {
+		t.Glamour = base.Glamour
+	}
+
+	fields := []struct {
+		dst *string
+		src string
+	}{
+		{&t.Palette.Accent, base.Palette.Accent},
+		{&t.Palette.AccentText, base.Palette.AccentText},
+		{&t.Palette.Muted, base.Palette.Muted},
+		{&t.Palette.Border, base.Palette.Border},
+		{&t.Palette.Success, base.Palette.Success},
+		{&t.Palette.Warning, base.Palette.Warning},
+		{&t.Palette.Error, base.Palette.Error},
+		{&t.Palette.Info, base.Palette.Info},
+	}
+	for _, f := range fields {
+		if *f.dst == "" {
+			*f.dst = f.src
+		}
+	}
+
+	return t
+}
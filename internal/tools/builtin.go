@@ -0,0 +1,204 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/indeedhat/term-gpt/internal/workdir"
+)
+
+const (
+	// maxOutputSize is the maximum number of bytes a built in tool will return to the model
+	maxOutputSize = 32 * 1024
+	// commandTimeout is the maximum amount of time a shell command is allowed to run for
+	commandTimeout = 30 * time.Second
+)
+
+// ReadFile lets the model read the content of a local text file
+type ReadFile struct{}
+
+// Name implements Tool.
+func (ReadFile) Name() string {
+	return "read_file"
+}
+
+// Description implements Tool.
+func (ReadFile) Description() string {
+	return "Read the content of a text file on the users machine"
+}
+
+// Parameters implements Tool.
+func (ReadFile) Parameters() any {
+	return schema([]string{"path"}, map[string]string{
+		"path": "Path of the file to read, relative to the current working directory",
+	})
+}
+
+// RequiresConfirmation implements Tool.
+// files outside of the working directory can hold secrets so reading them has to be approved
+func (ReadFile) RequiresConfirmation(args string) bool {
+	return !workdir.Contains(pathArg(args))
+}
+
+// Call implements Tool.
+func (ReadFile) Call(_ context.Context, args string) (string, error) {
+	var params struct {
+		Path string `json:"path"`
+	}
+	if err := decodeArgs(args, &params); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(params.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if !utf8.Valid(data) {
+		return "", errors.New("file is not a text file")
+	}
+
+	return truncate(string(data)), nil
+}
+
+var _ Tool = (*ReadFile)(nil)
+
+// ListDirectory lets the model list the entries in a local directory
+type ListDirectory struct{}
+
+// Name implements Tool.
+func (ListDirectory) Name() string {
+	return "list_directory"
+}
+
+// Description implements Tool.
+func (ListDirectory) Description() string {
+	return "List the files and directories contained in a directory on the users machine"
+}
+
+// Parameters implements Tool.
+func (ListDirectory) Parameters() any {
+	return schema([]string{"path"}, map[string]string{
+		"path": "Path of the directory to list, relative to the current working directory",
+	})
+}
+
+// RequiresConfirmation implements Tool.
+// directories outside of the working directory have to be approved
+func (ListDirectory) RequiresConfirmation(args string) bool {
+	return !workdir.Contains(pathArg(args))
+}
+
+// Call implements Tool.
+func (ListDirectory) Call(_ context.Context, args string) (string, error) {
+	var params struct {
+		Path string `json:"path"`
+	}
+	if err := decodeArgs(args, &params); err != nil {
+		return "", err
+	}
+
+	if params.Path == "" {
+		params.Path = "."
+	}
+
+	entries, err := os.ReadDir(params.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+
+		buf.WriteString(name + "\n")
+	}
+
+	return truncate(buf.String()), nil
+}
+
+var _ Tool = (*ListDirectory)(nil)
+
+// RunCommand lets the model run a shell command on the users machine
+// every call must be confirmed by the user before it is run
+type RunCommand struct{}
+
+// Name implements Tool.
+func (RunCommand) Name() string {
+	return "run_command"
+}
+
+// Description implements Tool.
+func (RunCommand) Description() string {
+	return "Run a shell command on the users machine and return its combined stdout and stderr output"
+}
+
+// Parameters implements Tool.
+func (RunCommand) Parameters() any {
+	return schema([]string{"command"}, map[string]string{
+		"command": "The command to run, it will be interpreted by sh",
+	})
+}
+
+// RequiresConfirmation implements Tool.
+func (RunCommand) RequiresConfirmation(string) bool {
+	return true
+}
+
+// Call implements Tool.
+func (RunCommand) Call(ctx context.Context, args string) (string, error) {
+	var params struct {
+		Command string `json:"command"`
+	}
+	if err := decodeArgs(args, &params); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "sh", "-c", params.Command).CombinedOutput()
+	if err != nil {
+		return truncate(fmt.Sprintf("%s\n%s", out, err)), nil
+	}
+
+	return truncate(string(out)), nil
+}
+
+var _ Tool = (*RunCommand)(nil)
+
+// pathArg extracts the path from the arguments of the file tools, invalid arguments give an empty
+// path as the call will fail anyway
+func pathArg(args string) string {
+	var params struct {
+		Path string `json:"path"`
+	}
+	if err := decodeArgs(args, &params); err != nil {
+		return ""
+	}
+
+	return params.Path
+}
+
+// truncate limits the output of a tool to maxOutputSize bytes
+func truncate(output string) string {
+	if len(output) <= maxOutputSize {
+		return output
+	}
+
+	cut := maxOutputSize
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+
+	return output[:cut] + "\n[output truncated]"
+}
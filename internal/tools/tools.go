@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrDuplicateTool is returned when registering a tool with the same name as one already registered
+var ErrDuplicateTool = errors.New("a tool with the same name is already registered")
+
+// Tool is a local function that the model is able to call via the openai tools API
+type Tool interface {
+	// Name is the function name that the model will use to call the tool
+	Name() string
+	// Description tells the model what the tool does and when to use it
+	Description() string
+	// Parameters is the json schema describing the arguments the tool accepts
+	Parameters() any
+	// RequiresConfirmation reports if the user must approve the call with the json encoded
+	// arguments provided by the model before it runs
+	RequiresConfirmation(args string) bool
+	// Call runs the tool with the json encoded arguments provided by the model
+	Call(ctx context.Context, args string) (string, error)
+}
+
+// Registry holds the set of tools that are made available to the model
+type Registry struct {
+	tools map[string]Tool
+	order []string
+}
+
+// NewRegistry creates a new registry containing the given tools, tools with the same name as an
+// earlier one are left out
+func NewRegistry(tools ...Tool) *Registry {
+	r := &Registry{tools: make(map[string]Tool)}
+
+	for _, tool := range tools {
+		r.Register(tool)
+	}
+
+	return r
+}
+
+// Builtin creates a registry containing all of the built in tools
+func Builtin() *Registry {
+	return NewRegistry(
+		ReadFile{},
+		ListDirectory{},
+		RunCommand{},
+	)
+}
+
+// Register adds a tool to the registry
+// if a tool with the same name already exists it is kept and an error is returned
+func (r *Registry) Register(tool Tool) error {
+	if _, ok := r.tools[tool.Name()]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateTool, tool.Name())
+	}
+
+	r.order = append(r.order, tool.Name())
+	r.tools[tool.Name()] = tool
+
+	return nil
+}
+
+// Get finds a tool by name
+func (r *Registry) Get(name string) (Tool, bool) {
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// List returns all the registered tools in the order they were registered
+func (r *Registry) List() []Tool {
+	tools := make([]Tool, 0, len(r.order))
+
+	for _, name := range r.order {
+		tools = append(tools, r.tools[name])
+	}
+
+	return tools
+}
+
+// Definitions describes all the registered tools in the format expected by the openai API
+func (r *Registry) Definitions() []openai.Tool {
+	defs := make([]openai.Tool, 0, len(r.order))
+
+	for _, tool := range r.List() {
+		defs = append(defs, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionDefinition{
+				Name:        tool.Name(),
+				Description: tool.Description(),
+				Parameters:  tool.Parameters(),
+			},
+		})
+	}
+
+	return defs
+}
+
+// decodeArgs unmarshals the json arguments provided by the model into dst
+func decodeArgs(args string, dst any) error {
+	if err := json.Unmarshal([]byte(args), dst); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	return nil
+}
+
+// schema is a small helper for building the json schema of an object with string properties
+func schema(required []string, props map[string]string) map[string]any {
+	properties := make(map[string]any, len(props))
+	for name, desc := range props {
+		properties[name] = map[string]string{
+			"type":        "string",
+			"description": desc,
+		}
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
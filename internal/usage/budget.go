@@ -0,0 +1,94 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// BudgetBlock stops requests that would exceed the budget from being sent
+	BudgetBlock = "block"
+	// BudgetConfirm asks the user before sending requests that would exceed the budget
+	BudgetConfirm = "confirm"
+)
+
+// Budget sets limits on the amount spent on requests, a limit of 0 means no limit
+type Budget struct {
+	// Daily is the max spend in USD per calendar day
+	Daily float64 `toml:"daily"`
+	// Monthly is the max spend in USD per calendar month
+	Monthly float64 `toml:"monthly"`
+	// Action decides what happens when a request would exceed the budget (block or confirm)
+	Action string `toml:"action"`
+}
+
+// ExceededError describes the budget that a request would exceed
+type ExceededError struct {
+	Period   string
+	Limit    float64
+	Spent    float64
+	Estimate float64
+}
+
+// Error implements error.
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf(
+		"this request (~$%.4f) would exceed your %s budget of $%.2f ($%.4f already spent)",
+		e.Estimate,
+		e.Period,
+		e.Limit,
+		e.Spent,
+	)
+}
+
+// Validate checks the budget settings are usable
+func (b Budget) Validate() error {
+	var errs []error
+
+	if b.Daily < 0 || b.Monthly < 0 {
+		errs = append(errs, errors.New("budget limits must not be negative"))
+	}
+	if b.Action != BudgetBlock && b.Action != BudgetConfirm {
+		errs = append(errs, fmt.Errorf("budget action must be %s or %s, got %q", BudgetBlock, BudgetConfirm, b.Action))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Enabled reports if any budget limits have been set
+func (b Budget) Enabled() bool {
+	return b.Daily > 0 || b.Monthly > 0
+}
+
+// Check compares the estimated cost of a request against the remaining budget
+// an *ExceededError is returned if the request would take the spend over either limit
+func (b Budget) Check(spentToday, spentMonth, estimate float64) error {
+	if b.Daily > 0 && spentToday+estimate > b.Daily {
+		return &ExceededError{Period: "daily", Limit: b.Daily, Spent: spentToday, Estimate: estimate}
+	}
+
+	if b.Monthly > 0 && spentMonth+estimate > b.Monthly {
+		return &ExceededError{Period: "monthly", Limit: b.Monthly, Spent: spentMonth, Estimate: estimate}
+	}
+
+	return nil
+}
+
+// StartOfDay returns midnight at the start of the day t falls in
+func StartOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// StartOfMonth returns midnight at the start of the month t falls in
+func StartOfMonth(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+}
+
+// EstimateTokens gives a rough estimate of the number of tokens in a piece of text
+// openai models average around 4 characters per token for english text
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
@@ -0,0 +1,39 @@
+package usage
+
+// ContextWindows maps model names (or name prefixes) to the max number of tokens in their context
+type ContextWindows map[string]int
+
+// DefaultContextWindows contains the published context sizes for the openai chat models
+var DefaultContextWindows = ContextWindows{
+	"gpt-3.5-turbo":        4_096,
+	"gpt-3.5-turbo-1106":   16_385,
+	"gpt-3.5-turbo-0125":   16_385,
+	"gpt-3.5-turbo-16k":    16_385,
+	"gpt-4":                8_192,
+	"gpt-4-32k":            32_768,
+	"gpt-4-1106-preview":   128_000,
+	"gpt-4-0125-preview":   128_000,
+	"gpt-4-vision-preview": 128_000,
+	"gpt-4-turbo":          128_000,
+	"gpt-4o":               128_000,
+	"gpt-4o-mini":          128_000,
+}
+
+// Merge returns a copy of the context windows with the overrides applied over the top
+func (c ContextWindows) Merge(overrides ContextWindows) ContextWindows {
+	merged := make(ContextWindows, len(c)+len(overrides))
+
+	for model, size := range c {
+		merged[model] = size
+	}
+	for model, size := range overrides {
+		merged[model] = size
+	}
+
+	return merged
+}
+
+// Lookup finds the context size of a model, matched on the longest prefix in the same way as Pricing
+func (c ContextWindows) Lookup(model string) (int, bool) {
+	return longestPrefix(c, model)
+}
@@ -0,0 +1,83 @@
+package usage
+
+import (
+	"strings"
+)
+
+// tokensPerPrice is the number of tokens each Price is quoted for
+const tokensPerPrice = 1_000_000
+
+// Price is the cost in USD per million tokens for a model
+type Price struct {
+	Prompt     float64 `toml:"prompt"`
+	Completion float64 `toml:"completion"`
+}
+
+// Pricing maps model names (or name prefixes) to their price
+type Pricing map[string]Price
+
+// DefaultPricing contains the published prices for the openai chat models
+var DefaultPricing = Pricing{
+	"gpt-3.5-turbo":        {Prompt: 1.5, Completion: 2},
+	"gpt-3.5-turbo-1106":   {Prompt: 1, Completion: 2},
+	"gpt-3.5-turbo-0125":   {Prompt: 0.5, Completion: 1.5},
+	"gpt-3.5-turbo-16k":    {Prompt: 3, Completion: 4},
+	"gpt-4":                {Prompt: 30, Completion: 60},
+	"gpt-4-32k":            {Prompt: 60, Completion: 120},
+	"gpt-4-1106-preview":   {Prompt: 10, Completion: 30},
+	"gpt-4-0125-preview":   {Prompt: 10, Completion: 30},
+	"gpt-4-vision-preview": {Prompt: 10, Completion: 30},
+	"gpt-4-turbo":          {Prompt: 10, Completion: 30},
+	"gpt-4o":               {Prompt: 5, Completion: 15},
+	"gpt-4o-mini":          {Prompt: 0.15, Completion: 0.6},
+}
+
+// Merge returns a copy of the pricing table with the overrides applied over the top
+func (p Pricing) Merge(overrides Pricing) Pricing {
+	merged := make(Pricing, len(p)+len(overrides))
+
+	for model, price := range p {
+		merged[model] = price
+	}
+	for model, price := range overrides {
+		merged[model] = price
+	}
+
+	return merged
+}
+
+// Lookup finds the price for a model
+//
+// Models are matched on the longest prefix so that dated versions of a model (gpt-4-0613) use
+// the price of their base model (gpt-4) unless they have their own entry
+func (p Pricing) Lookup(model string) (Price, bool) {
+	return longestPrefix(p, model)
+}
+
+// longestPrefix finds the entry whose name is the longest prefix of model
+func longestPrefix[V any](table map[string]V, model string) (V, bool) {
+	var (
+		best    V
+		bestLen = -1
+	)
+
+	for name, val := range table {
+		if strings.HasPrefix(model, name) && len(name) > bestLen {
+			best = val
+			bestLen = len(name)
+		}
+	}
+
+	return best, bestLen >= 0
+}
+
+// Cost calculates the cost in USD of a request
+// models missing from the pricing table are treated as free
+func (p Pricing) Cost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := p.Lookup(model)
+	if !ok {
+		return 0
+	}
+
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / tokensPerPrice
+}
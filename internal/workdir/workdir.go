@@ -0,0 +1,73 @@
+package workdir
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Contains reports if path is inside the current working directory once any symlinks have been
+// followed, an empty path is the working directory itself
+//
+// Paths that don't exist yet are checked by following the symlinks of the nearest parent that does
+// so a new file can't be written through a linked directory that leads elsewhere
+func Contains(path string) bool {
+	if path == "" {
+		path = "."
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return false
+	}
+	if wd, err = filepath.EvalSymlinks(wd); err != nil {
+		return false
+	}
+
+	// the path is joined by hand as filepath.Join would clean away any .. that follows a symlink
+	if !filepath.IsAbs(path) {
+		path = wd + string(filepath.Separator) + path
+	}
+
+	resolved, err := resolve(path)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(wd, resolved)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolve follows the symlinks in path, the parts at the end of the path that don't exist are
+// added back on to the nearest parent that does
+func resolve(path string) (string, error) {
+	var missing []string
+
+	for {
+		for len(path) > 1 && os.IsPathSeparator(path[len(path)-1]) {
+			path = path[:len(path)-1]
+		}
+
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		dir, file := filepath.Split(path)
+		if file == "" {
+			return "", err
+		}
+
+		missing = append([]string{file}, missing...)
+		path = dir
+	}
+}
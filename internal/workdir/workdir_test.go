@@ -0,0 +1,77 @@
+package workdir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chdirTemp moves into a new temp directory holding a file, a directory and a symlink to a
+// directory outside of it, the working directory is restored when the test ends
+// both the new working directory and the directory outside of it are returned
+func chdirTemp(t *testing.T) (string, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	for _, dir := range []string{filepath.Join(root, "dir"), filepath.Join(outside, "lib")} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "file.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "lib"), filepath.Join(root, "vendor")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "dir"), filepath.Join(root, "local")); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	return root, outside
+}
+
+func TestContains(t *testing.T) {
+	root, outside := chdirTemp(t)
+
+	cases := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"empty path", "", true},
+		{"existing file", "file.go", true},
+		{"new file", "dir/new.go", true},
+		{"new file in new directory", "dir/a/b/new.go", true},
+		{"trailing slash", "dir/", true},
+		{"absolute path inside", filepath.Join(root, "file.go"), true},
+		{"symlink inside", "local/new.go", true},
+		{"parent", "..", false},
+		{"escapes with dots", "dir/../../file.go", false},
+		{"dots in new directory", "dir/a/../../../file.go", false},
+		{"absolute path outside", filepath.Join(outside, "lib"), false},
+		{"symlinked directory", "vendor", false},
+		{"dots after symlink", "vendor/../lib", false},
+		{"new file through symlink", "vendor/new.go", false},
+		{"new directory through symlink", "vendor/a/new.go", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Contains(c.path); got != c.want {
+				t.Errorf("Contains(%q) = %v, want %v", c.path, got, c.want)
+			}
+		})
+	}
+}